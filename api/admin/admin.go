@@ -0,0 +1,44 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package admin handles /admin... routes.
+package admin
+
+import (
+	"net/http"
+
+	"github.com/golang/glog"
+
+	"github.com/DevMine/api-server/cache"
+	"github.com/DevMine/api-server/srv/context"
+	"github.com/DevMine/api-server/util/httputil"
+	"github.com/DevMine/api-server/util/json"
+)
+
+// ReloadCache handles "/admin/cache/reload" route.
+// The cache is reloaded in the background and the status of the cache is
+// returned right away.
+func ReloadCache(c *context.Context, w http.ResponseWriter, r *http.Request) error {
+	errc, err := cache.ReloadAsync()
+	switch err {
+	case nil:
+	case cache.ErrReloadInProgress:
+		return httputil.NewError(http.StatusConflict, httputil.CodeConflict, err.Error())
+	default:
+		return err
+	}
+
+	glog.Info("reloading cache...")
+	go func() {
+		if err := <-errc; err != nil {
+			glog.Error("cache reload failed: ", err)
+			return
+		}
+		glog.Info("cache reloaded in ", cache.Current().LoadDuration())
+	}()
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(json.MarshalIndentPanic(cache.Status()))
+	return nil
+}
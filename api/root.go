@@ -17,6 +17,7 @@ type api struct {
 }
 
 // Index handles "/" route.
-func Index(c *context.Context, w http.ResponseWriter, r *http.Request) {
+func Index(c *context.Context, w http.ResponseWriter, r *http.Request) error {
 	w.Write(json.MarshalIndentPanic(api{Version: Version, DocURL: DocURL}))
+	return nil
 }
@@ -0,0 +1,95 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package search
+
+import (
+	stdjson "encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/DevMine/api-server/cache"
+	"github.com/DevMine/api-server/model"
+	"github.com/DevMine/api-server/score"
+	"github.com/DevMine/api-server/srv/context"
+	"github.com/DevMine/api-server/util/httputil"
+	"github.com/DevMine/api-server/util/json"
+	"github.com/DevMine/api-server/util/typeutil"
+)
+
+const (
+	// defaultNeighbors is the number of users ranked just above and just
+	// below a user returned when the request does not specify any.
+	defaultNeighbors = 2
+
+	// maxNeighbors is the maximum number of users ranked just above and just
+	// below a user.
+	maxNeighbors = 10
+)
+
+var (
+	// errUserNotFound is returned when the requested user does not exist.
+	errUserNotFound = httputil.NotFound("user not found")
+
+	// errUserNotRanked is returned when the requested user is not ranked by
+	// the query, because of its filters or minimum rank.
+	errUserNotRanked = httputil.NotFound("user not ranked by the query")
+)
+
+// UserRank handles "/users/{username}/rank" route. The search query is given
+// in the "query" parameter and defaults to an empty query, which ranks users
+// with the default weights.
+func (s *Searcher) UserRank(c *context.Context, w http.ResponseWriter, r *http.Request) error {
+	params := r.URL.Query()
+
+	q := new(model.SearchQuery)
+	if query := params.Get("query"); len(query) > 0 {
+		dec := stdjson.NewDecoder(strings.NewReader(query))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(q); err != nil {
+			return httputil.BadRequest(httputil.CodeInvalidJSON,
+				fmt.Sprintf("invalid JSON input: %v", err))
+		}
+	}
+	s.applyParams(q, params)
+
+	neighbors := defaultNeighbors
+	if n := params.Get("neighbors"); len(n) > 0 {
+		v, err := typeutil.StrToUint(n)
+		if err != nil || v > maxNeighbors {
+			return httputil.BadRequest(httputil.CodeInvalidParameter,
+				fmt.Sprintf("neighbors must be between 0 and %d", maxNeighbors))
+		}
+		neighbors = int(v)
+	}
+
+	snap := cache.Current()
+
+	if err := validateQuery(snap, q); err != nil {
+		return err
+	}
+	if q.Mode == model.SearchModeSkyline || q.Diversity.Lambda != nil {
+		return httputil.BadRequest(httputil.CodeInvalidParameter,
+			"positions can only be computed in rank mode, without diversity re-ranking")
+	}
+
+	row, ok := snap.UserRowByUsername(mux.Vars(r)["username"])
+	if !ok {
+		return errUserNotFound
+	}
+
+	pos, err := score.Position(snap, q, row, neighbors)
+	if err != nil {
+		return err
+	}
+	if pos == nil {
+		return errUserNotRanked
+	}
+
+	w.Write(json.MarshalIndentPanic(pos))
+	return nil
+}
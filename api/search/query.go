@@ -0,0 +1,218 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package search
+
+import (
+	stdjson "encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/DevMine/api-server/cache"
+	"github.com/DevMine/api-server/model"
+	"github.com/DevMine/api-server/score"
+	"github.com/DevMine/api-server/util/httputil"
+)
+
+// maxQuerySize is the maximum size, in bytes, of a search query given in the
+// body of a request.
+const maxQuerySize = 1 << 20
+
+// resultFields are the fields of search results that can be selected with
+// the "fields" member of a search query.
+var resultFields = map[string]bool{
+	"id":          true,
+	"username":    true,
+	"name":        true,
+	"email":       true,
+	"rank":        true,
+	"layer":       true,
+	"explanation": true,
+}
+
+// parseQuery reads the search query of a request to the /search route.
+func parseQuery(r *http.Request) (*model.SearchQuery, error) {
+	q := new(model.SearchQuery)
+	if err := decodeQuery(r, q); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// decodeQuery decodes into v the JSON query given either in the body of a
+// POST request or in the "q" parameter of a GET request.
+func decodeQuery(r *http.Request, v interface{}) error {
+	var body io.Reader
+	switch r.Method {
+	case "POST":
+		body = http.MaxBytesReader(nil, r.Body, maxQuerySize)
+	default:
+		q := r.URL.Query().Get("q")
+		if len(q) == 0 {
+			return httputil.BadRequest(httputil.CodeInvalidParameter,
+				"missing search query parameter: q")
+		}
+		body = strings.NewReader(q)
+	}
+
+	dec := stdjson.NewDecoder(body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return httputil.NewError(http.StatusRequestEntityTooLarge, httputil.CodeRequestTooLarge,
+				fmt.Sprintf("search query cannot exceed %d bytes", tooLarge.Limit))
+		}
+		return httputil.BadRequest(httputil.CodeInvalidJSON,
+			fmt.Sprintf("invalid JSON input: %v", err))
+	}
+
+	return nil
+}
+
+// validateQuery checks that the features and fields referenced by search
+// query q exist in the given snapshot and that its weights and ranges are
+// valid.
+func validateQuery(snap *cache.Snapshot, q *model.SearchQuery) error {
+	featuresNames := snap.FeaturesNames()
+
+	for feat, weight := range q.Weights {
+		if _, ok := featuresNames[feat]; !ok {
+			return unknownFeature(feat)
+		}
+
+		if weight < 0 && !q.Ranking.AllowNegativeWeights {
+			return httputil.BadRequest(httputil.CodeInvalidWeight,
+				"negative weight given, set allow_negative_weights to penalize a feature")
+		}
+	}
+
+	if err := validateFilters(snap, q.Filters); err != nil {
+		return err
+	}
+
+	switch q.Mode {
+	case "", model.SearchModeRank:
+	case model.SearchModeSkyline:
+		if len(q.Skyline.Features) == 0 {
+			return httputil.BadRequest(httputil.CodeInvalidParameter,
+				"skyline queries require at least one feature")
+		}
+		for _, feat := range q.Skyline.Features {
+			if _, ok := featuresNames[strings.TrimPrefix(feat, "-")]; !ok {
+				return unknownFeature(feat)
+			}
+		}
+		if q.Skyline.Layer < 0 {
+			return httputil.BadRequest(httputil.CodeInvalidParameter,
+				"skyline layer cannot be negative")
+		}
+	default:
+		return httputil.BadRequest(httputil.CodeInvalidParameter,
+			fmt.Sprintf("unknown search mode: %s", q.Mode))
+	}
+
+	if l := q.Diversity.Lambda; l != nil {
+		if *l < 0 || *l > 1 {
+			return httputil.BadRequest(httputil.CodeInvalidParameter,
+				"diversity lambda must be between 0 and 1")
+		}
+		if q.Mode == model.SearchModeSkyline {
+			return httputil.BadRequest(httputil.CodeInvalidParameter,
+				"skyline results cannot be diversified")
+		}
+	}
+	if len(q.Diversity.By) > 0 && !score.IsDiversity(q.Diversity.By) {
+		return httputil.BadRequest(httputil.CodeInvalidParameter,
+			fmt.Sprintf("unknown diversity criterion: %s", q.Diversity.By))
+	}
+
+	if !score.IsNormalization(q.Ranking.Normalization) {
+		return httputil.BadRequest(httputil.CodeInvalidParameter,
+			fmt.Sprintf("unknown normalization: %s", q.Ranking.Normalization))
+	}
+
+	if !score.IsRanker(q.Ranking.Ranker) {
+		return httputil.BadRequest(httputil.CodeInvalidParameter,
+			fmt.Sprintf("unknown ranker: %s, available rankers: %s",
+				q.Ranking.Ranker, strings.Join(score.Rankers(), ", ")))
+	}
+
+	if q.Ranking.P != 0 && q.Ranking.P < 1 {
+		return httputil.BadRequest(httputil.CodeInvalidParameter,
+			"the order of the norm must be greater than or equal to 1")
+	}
+
+	if q.Explain && len(q.Ranking.Ranker) > 0 && q.Ranking.Ranker != score.DefaultRanker {
+		return httputil.BadRequest(httputil.CodeInvalidParameter,
+			"only the ranks computed by the "+score.DefaultRanker+" ranker can be explained")
+	}
+
+	for _, field := range q.Fields {
+		if !resultFields[field] {
+			return httputil.BadRequest(httputil.CodeInvalidParameter,
+				fmt.Sprintf("unknown field: %s", field))
+		}
+	}
+
+	return nil
+}
+
+// validateFilters checks that the features referenced by the given filters
+// exist in the given snapshot and that their ranges are valid.
+func validateFilters(snap *cache.Snapshot, filters model.SearchFilters) error {
+	featuresNames := snap.FeaturesNames()
+
+	for feat, sr := range filters.Scores {
+		if _, ok := featuresNames[feat]; !ok {
+			return unknownFeature(feat)
+		}
+
+		if sr.Min != nil && sr.Max != nil && *sr.Min > *sr.Max {
+			return httputil.BadRequest(httputil.CodeInvalidParameter,
+				fmt.Sprintf("empty score range for feature: %s", feat))
+		}
+	}
+
+	if fr := filters.Followers; fr != nil && fr.Min != nil && fr.Max != nil && *fr.Min > *fr.Max {
+		return httputil.BadRequest(httputil.CodeInvalidParameter, "empty followers range")
+	}
+
+	return nil
+}
+
+// unknownFeature returns the error reporting that a query references a
+// feature which does not exist.
+func unknownFeature(feat string) error {
+	return httputil.BadRequest(httputil.CodeUnknownFeature,
+		fmt.Sprintf("non existing feature: %s", feat))
+}
+
+// selectFields returns the search results with only the given fields.
+func selectFields(results model.SearchResults, fields []string) []map[string]stdjson.RawMessage {
+	selected := make([]map[string]stdjson.RawMessage, len(results))
+	for i, res := range results {
+		bs, err := stdjson.Marshal(res)
+		if err != nil {
+			panic(err)
+		}
+
+		var all map[string]stdjson.RawMessage
+		if err := stdjson.Unmarshal(bs, &all); err != nil {
+			panic(err)
+		}
+
+		selected[i] = make(map[string]stdjson.RawMessage, len(fields))
+		for _, field := range fields {
+			if v, ok := all[field]; ok {
+				selected[i][field] = v
+			}
+		}
+	}
+	return selected
+}
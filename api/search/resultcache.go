@@ -0,0 +1,167 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package search
+
+import (
+	"container/list"
+	stdjson "encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/DevMine/api-server/cache"
+	"github.com/DevMine/api-server/model"
+	"github.com/DevMine/api-server/score"
+)
+
+// resultCache is a least recently used cache of the ranked results of search
+// queries, keyed by canonical query. All the entries have been computed from
+// snapshots of the same cache generation.
+// Cached results are shared and must not be modified.
+type resultCache struct {
+	mu sync.Mutex
+
+	// size is the maximum number of entries.
+	size int
+
+	// generation is the cache generation of the entries.
+	generation uint64
+
+	entries map[string]*list.Element
+
+	// order holds the entries, from the most to the least recently used.
+	order *list.List
+}
+
+// resultCacheEntry is an entry of a resultCache.
+type resultCacheEntry struct {
+	key     string
+	results model.SearchResults
+}
+
+// newResultCache creates a cache holding the results of at most size
+// queries.
+func newResultCache(size int) *resultCache {
+	return &resultCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// get returns the results cached for the given key, provided they have been
+// computed from a snapshot of the given generation.
+func (rc *resultCache) get(generation uint64, key string) (model.SearchResults, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if generation != rc.generation {
+		return nil, false
+	}
+
+	elem, ok := rc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	rc.order.MoveToFront(elem)
+	return elem.Value.(*resultCacheEntry).results, true
+}
+
+// add caches the results, computed from a snapshot of the given generation,
+// for the given key. Entries of other generations are dropped and the least
+// recently used entry is evicted when the cache is full.
+func (rc *resultCache) add(generation uint64, key string, results model.SearchResults) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	switch {
+	case generation < rc.generation:
+		// computed from an outdated snapshot
+		return
+	case generation > rc.generation:
+		rc.generation = generation
+		rc.entries = make(map[string]*list.Element)
+		rc.order.Init()
+	}
+
+	if elem, ok := rc.entries[key]; ok {
+		elem.Value.(*resultCacheEntry).results = results
+		rc.order.MoveToFront(elem)
+		return
+	}
+
+	rc.entries[key] = rc.order.PushFront(&resultCacheEntry{key: key, results: results})
+	if rc.order.Len() > rc.size {
+		oldest := rc.order.Back()
+		rc.order.Remove(oldest)
+		delete(rc.entries, oldest.Value.(*resultCacheEntry).key)
+	}
+}
+
+// canonicalQuery holds the members of a search query which the ranked
+// results depend on, in a canonical form.
+type canonicalQuery struct {
+	Weights []featureWeight      `json:"weights"`
+	Filters model.SearchFilters  `json:"filters"`
+	Ranking model.RankingOptions `json:"ranking"`
+}
+
+// featureWeight is the weight of a feature.
+type featureWeight struct {
+	Feature string  `json:"feature"`
+	Weight  float64 `json:"weight"`
+}
+
+// canonicalKey returns a key identifying the ranked results of query q,
+// which must have been validated against the given snapshot. Queries giving
+// the same results, such as queries only differing by the order of their
+// weights or by giving the default weight of a feature, have the same key.
+func canonicalKey(snap *cache.Snapshot, q *model.SearchQuery) string {
+	cq := canonicalQuery{Filters: q.Filters, Ranking: q.Ranking}
+
+	// features are sorted by name
+	features := snap.Features()
+	cq.Weights = make([]featureWeight, len(features))
+	for i, f := range features {
+		w, ok := q.Weights[*f.Name]
+		if !ok {
+			w = float64(*f.DefaultWeight)
+		}
+		cq.Weights[i] = featureWeight{Feature: *f.Name, Weight: w}
+	}
+
+	// profile filters are case insensitive and lists of organizations or
+	// languages are unordered
+	cq.Filters.Location = strings.ToLower(cq.Filters.Location)
+	cq.Filters.Company = strings.ToLower(cq.Filters.Company)
+	cq.Filters.Organizations = canonicalList(cq.Filters.Organizations)
+	cq.Filters.Languages = canonicalList(cq.Filters.Languages)
+
+	if len(cq.Ranking.Ranker) == 0 {
+		cq.Ranking.Ranker = score.DefaultRanker
+	}
+	// negative weights are validated beforehand and do not change results
+	cq.Ranking.AllowNegativeWeights = false
+
+	// maps are marshaled with sorted keys
+	bs, err := stdjson.Marshal(cq)
+	if err != nil {
+		panic(err)
+	}
+	return string(bs)
+}
+
+// canonicalList returns a sorted copy, in lower case, of the given list.
+func canonicalList(l []string) []string {
+	if len(l) == 0 {
+		return nil
+	}
+	c := make([]string, len(l))
+	for i, s := range l {
+		c[i] = strings.ToLower(s)
+	}
+	sort.Strings(c)
+	return c
+}
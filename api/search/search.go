@@ -2,59 +2,249 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// Package search handles /search... routes.
+// Package search handles /search... and /teams routes.
 package search
 
 import (
 	stdjson "encoding/json"
-	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 
 	"github.com/DevMine/api-server/cache"
+	"github.com/DevMine/api-server/config"
+	"github.com/DevMine/api-server/metrics"
+	"github.com/DevMine/api-server/model"
 	"github.com/DevMine/api-server/score"
 	"github.com/DevMine/api-server/srv/context"
 	"github.com/DevMine/api-server/util/httputil"
-	"github.com/DevMine/api-server/util/json"
 )
 
-// numberOfResults represents the number of results to return
-// from a search query.
-const numberOfResults = 1000
+// defaultMaxResults is the maximum number of ranked results that can be
+// reached when none is configured.
+const defaultMaxResults = 1000
 
-// Query handles "/search/{query}" route.
-func Query(c *context.Context, w http.ResponseWriter, r *http.Request) {
+// maxPerPage is the maximum number of results per page.
+const maxPerPage = 100
+
+// errCursorNotSupported is returned when search results are requested using
+// cursor based pagination.
+var errCursorNotSupported = httputil.BadRequest(httputil.CodeInvalidParameter,
+	"search results can only be paginated using page numbers")
+
+// Searcher handles the /search... routes.
+type Searcher struct {
+	// maxResults is the maximum number of ranked results that can be reached
+	// by paginating.
+	maxResults int
+
+	// normalization is the normalization mode of queries which do not
+	// specify any.
+	normalization string
+
+	// results caches the ranked results of queries. It is nil when caching
+	// is disabled.
+	results *resultCache
+}
+
+// New creates a Searcher from the search configuration.
+func New(cfg config.SearchConfig) *Searcher {
+	s := &Searcher{maxResults: cfg.MaxResults, normalization: cfg.Normalization}
+	if s.maxResults == 0 {
+		s.maxResults = defaultMaxResults
+	}
+	if len(s.normalization) == 0 {
+		s.normalization = score.NormNone
+	}
+	if cfg.ResultCacheSize > 0 {
+		s.results = newResultCache(cfg.ResultCacheSize)
+	}
+	return s
+}
+
+// Query handles "/search/{query}" route, where the query is a JSON object of
+// feature names with their weights.
+func (s *Searcher) Query(c *context.Context, w http.ResponseWriter, r *http.Request) error {
 	vars := mux.Vars(r)
-	query := map[string]int64{}
+	weights := map[string]float64{}
+
+	if err := stdjson.Unmarshal([]byte(vars["query"]), &weights); err != nil {
+		return httputil.BadRequest(httputil.CodeInvalidJSON, "invalid JSON input")
+	}
+
+	return s.search(c, w, r, &model.SearchQuery{Weights: weights})
+}
 
-	if err := stdjson.Unmarshal([]byte(vars["query"]), &query); err != nil {
-		he := httputil.NewResponseError("invalid JSON input")
-		http.Error(w, he.JSON(), http.StatusBadRequest)
-		return
+// Search handles "/search" route. The search query is given either in the
+// body of a POST request or in the "q" parameter of a GET request.
+func (s *Searcher) Search(c *context.Context, w http.ResponseWriter, r *http.Request) error {
+	q, err := parseQuery(r)
+	if err != nil {
+		return err
+	}
+
+	return s.search(c, w, r, q)
+}
+
+// search ranks users according to query q, or computes their skyline layers
+// in skyline mode, and writes the requested page of results.
+// Results are paginated using page numbers, up to the configured maximum
+// number of results.
+func (s *Searcher) search(c *context.Context, w http.ResponseWriter, r *http.Request, q *model.SearchQuery) error {
+	if c.CursorMode {
+		return errCursorNotSupported
 	}
 
-	featuresNames := cache.GetFeaturesNames()
+	params := r.URL.Query()
+	s.applyParams(q, params)
+
+	// use a single snapshot to validate, rank and explain
+	snap := cache.Current()
+
+	if err := validateQuery(snap, q); err != nil {
+		return err
+	}
 
-	for feat, weight := range query {
-		if _, ok := featuresNames[feat]; !ok {
-			he := httputil.NewResponseError(fmt.Sprintf("non existing feature: %s", feat))
-			http.Error(w, he.JSON(), http.StatusBadRequest)
-			return
+	// pagination parameters of the request take precedence over the ones of
+	// the query, so that the links of the Link header are honoured
+	if q.Page > 0 && len(params.Get("page")) == 0 {
+		c.PageNumber = q.Page
+	}
+	if q.PerPage > 0 && len(params.Get("per_page")) == 0 {
+		c.PerPage = q.PerPage
+		if c.PerPage > maxPerPage {
+			c.PerPage = maxPerPage
 		}
+	}
+
+	// streamed results are not paginated: all the results that can be
+	// reached, at most maxResults, are written once they are all ranked
+	offset, limit := c.Offset(), c.PerPage
+	if c.Streaming() {
+		offset, limit = 0, uint64(s.maxResults)
+	}
 
-		if weight < 0 {
-			he := httputil.NewResponseError("negative weight given")
-			http.Error(w, he.JSON(), http.StatusBadRequest)
-			return
+	var (
+		ranks model.SearchResults
+		total int
+		err   error
+	)
+	switch q.Mode {
+	case model.SearchModeSkyline:
+		tic := time.Now()
+		// skyline layers are only computed up to the requested page
+		ranks, total, err = score.Skyline(snap, q, int(offset+limit))
+		if total > s.maxResults {
+			total = s.maxResults
 		}
+		metrics.ObserveRank(time.Since(tic))
+	default:
+		ranks, err = s.rank(snap, q)
+		total = len(ranks)
+		if err == nil && q.Diversity.Lambda != nil {
+			// results are re-ranked up to the requested page, in a copy as
+			// ranked results may be cached
+			ranks = append(model.SearchResults(nil), ranks...)
+			err = score.Diversify(snap, q, ranks, int(offset+limit))
+		}
+	}
+	if err != nil {
+		return err
 	}
 
-	ranks, err := score.Rank(c.DB, query)
+	page := s.page(ranks, offset, limit)
+	c.SetPageHeaders(w, int64(total), len(page), nil)
+
+	if q.Explain {
+		// explanations are set in a copy of the page, as ranked results may
+		// be cached
+		page = append(model.SearchResults(nil), page...)
+		if err := score.Explain(snap, q, page); err != nil {
+			return err
+		}
+	}
+
+	if len(q.Fields) == 0 {
+		return c.WriteList(w, page)
+	}
+	return c.WriteList(w, selectFields(page, q.Fields))
+}
+
+// rank returns the results of query q that can be reached, sorted by rank.
+// The results are shared with the result cache, if any, and must not be
+// modified.
+func (s *Searcher) rank(snap *cache.Snapshot, q *model.SearchQuery) (model.SearchResults, error) {
+	var key string
+	if s.results != nil {
+		key = canonicalKey(snap, q)
+		if ranks, ok := s.results.get(snap.Generation(), key); ok {
+			metrics.ObserveResultCache(true)
+			return ranks, nil
+		}
+		metrics.ObserveResultCache(false)
+	}
+
+	tic := time.Now()
+	ranks, err := score.Rank(snap, q)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	metrics.ObserveRank(time.Since(tic))
 
-	// return only 1000 first results
-	w.Write(json.MarshalPanic(ranks[:numberOfResults]))
+	// only keep the results that can be reached
+	ranks = append(model.SearchResults(nil), ranks[:s.total(ranks)]...)
+
+	if s.results != nil {
+		s.results.add(snap.Generation(), key, ranks)
+	}
+	return ranks, nil
+}
+
+// applyParams sets the options of query q given as request parameters, which
+// take precedence over the ones of the query, and the default options.
+func (s *Searcher) applyParams(q *model.SearchQuery, params url.Values) {
+	if explain, err := strconv.ParseBool(params.Get("explain")); err == nil {
+		q.Explain = explain
+	}
+	if allow, err := strconv.ParseBool(params.Get("allow_negative_weights")); err == nil {
+		q.Ranking.AllowNegativeWeights = allow
+	}
+	if norm := params.Get("normalization"); len(norm) > 0 {
+		q.Ranking.Normalization = norm
+	}
+	if ranker := params.Get("ranker"); len(ranker) > 0 {
+		q.Ranking.Ranker = ranker
+	}
+	if mode := params.Get("mode"); len(mode) > 0 {
+		q.Mode = mode
+	}
+
+	if len(q.Ranking.Normalization) == 0 {
+		q.Ranking.Normalization = s.normalization
+	}
+}
+
+// total returns the number of results that can be reached by paginating.
+func (s *Searcher) total(results model.SearchResults) int {
+	if len(results) > s.maxResults {
+		return s.maxResults
+	}
+	return len(results)
+}
+
+// page returns at most limit results, skipping the first offset ones.
+func (s *Searcher) page(results model.SearchResults, offset, limit uint64) model.SearchResults {
+	total := uint64(s.total(results))
+	if offset >= total {
+		return model.SearchResults{}
+	}
+
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return results[offset:end]
 }
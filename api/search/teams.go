@@ -0,0 +1,97 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package search
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/DevMine/api-server/cache"
+	"github.com/DevMine/api-server/metrics"
+	"github.com/DevMine/api-server/model"
+	"github.com/DevMine/api-server/score"
+	"github.com/DevMine/api-server/srv/context"
+	"github.com/DevMine/api-server/util/httputil"
+)
+
+const (
+	// defaultTeamsCount is the number of candidate teams returned when the
+	// query does not specify any.
+	defaultTeamsCount = 5
+
+	// maxTeamsCount is the maximum number of candidate teams.
+	maxTeamsCount = 20
+
+	// maxTeamSize is the maximum number of members of a team.
+	maxTeamSize = 10
+)
+
+// Teams handles "/teams" route. The team query is given either in the body of
+// a POST request or in the "q" parameter of a GET request.
+func (s *Searcher) Teams(c *context.Context, w http.ResponseWriter, r *http.Request) error {
+	q := new(model.TeamQuery)
+	if err := decodeQuery(r, q); err != nil {
+		return err
+	}
+
+	snap := cache.Current()
+
+	if err := validateTeamQuery(snap, q); err != nil {
+		return err
+	}
+	if q.Count == 0 {
+		q.Count = defaultTeamsCount
+	}
+	if q.MaxSize == 0 {
+		q.MaxSize = len(q.Requirements)
+		if q.MaxSize > maxTeamSize {
+			q.MaxSize = maxTeamSize
+		}
+	}
+
+	tic := time.Now()
+	teams, err := score.Teams(snap, q)
+	if err != nil {
+		return err
+	}
+	metrics.ObserveRank(time.Since(tic))
+
+	return c.WriteList(w, teams)
+}
+
+// validateTeamQuery checks that the features referenced by team query q
+// exist in the given snapshot and that its sizes are valid. A size of 0, as
+// when it is omitted, stands for its default value.
+func validateTeamQuery(snap *cache.Snapshot, q *model.TeamQuery) error {
+	if len(q.Requirements) == 0 {
+		return httputil.BadRequest(httputil.CodeInvalidParameter,
+			"team queries require at least one required feature")
+	}
+	if len(q.Requirements) > score.MaxRequirements {
+		return httputil.BadRequest(httputil.CodeInvalidParameter,
+			fmt.Sprintf("team queries cannot have more than %d requirements", score.MaxRequirements))
+	}
+
+	featuresNames := snap.FeaturesNames()
+	for feat := range q.Requirements {
+		if _, ok := featuresNames[feat]; !ok {
+			return unknownFeature(feat)
+		}
+	}
+
+	if q.MaxSize < 0 || q.MaxSize > maxTeamSize {
+		return httputil.BadRequest(httputil.CodeInvalidParameter,
+			fmt.Sprintf("team size must be between 1 and %d, or 0 for the number of requirements", maxTeamSize))
+	}
+
+	if q.Count < 0 || q.Count > maxTeamsCount {
+		return httputil.BadRequest(httputil.CodeInvalidParameter,
+			fmt.Sprintf("number of teams must be between 1 and %d, or 0 for the default of %d",
+				maxTeamsCount, defaultTeamsCount))
+	}
+
+	return validateFilters(snap, q.Filters)
+}
@@ -14,6 +14,13 @@ import (
 )
 
 // Index handles "/stats" route.
-func Index(c *context.Context, w http.ResponseWriter, r *http.Request) {
+func Index(c *context.Context, w http.ResponseWriter, r *http.Request) error {
 	w.Write(json.MarshalIndentPanic(cache.GetStats()))
+	return nil
+}
+
+// Cache handles "/stats/cache" route.
+func Cache(c *context.Context, w http.ResponseWriter, r *http.Request) error {
+	w.Write(json.MarshalIndentPanic(cache.Status()))
+	return nil
 }
@@ -0,0 +1,133 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package users
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/DevMine/api-server/cache"
+	"github.com/DevMine/api-server/config"
+	"github.com/DevMine/api-server/model"
+	"github.com/DevMine/api-server/score"
+	"github.com/DevMine/api-server/srv/context"
+	"github.com/DevMine/api-server/util/httputil"
+	"github.com/DevMine/api-server/util/typeutil"
+)
+
+const (
+	// defaultK is the number of similar users returned when the request
+	// does not specify any.
+	defaultK = 10
+
+	// defaultMaxK is the maximum number of similar users that can be
+	// requested when none is configured.
+	defaultMaxK = 100
+)
+
+// Similarity handles the "/users/{username}/similar" route.
+type Similarity struct {
+	// maxK is the maximum number of similar users that can be requested.
+	maxK int
+}
+
+// NewSimilarity creates a Similarity from the search configuration.
+func NewSimilarity(cfg config.SearchConfig) *Similarity {
+	s := &Similarity{maxK: cfg.MaxSimilar}
+	if s.maxK == 0 {
+		s.maxK = defaultMaxK
+	}
+	return s
+}
+
+// ShowSimilar handles "/users/{username:[a-zA-Z0-9\\-_\\.]+}/similar" route.
+// Users are compared using the scores from the cache, with the metric given
+// by the "metric" parameter (cosine by default) over the features given by
+// the comma separated "features" parameter (all by default). Scores can be
+// normalized first with the "normalization" parameter. Only the "k" most
+// similar users (10 by default) are returned, k being capped by the
+// configuration.
+func (s *Similarity) ShowSimilar(c *context.Context, w http.ResponseWriter, r *http.Request) error {
+	if c.CursorMode {
+		return httputil.BadRequest(httputil.CodeInvalidParameter,
+			"similar users can only be paginated using page numbers")
+	}
+
+	vars := mux.Vars(r)
+	username := vars["username"]
+	params := r.URL.Query()
+
+	k := defaultK
+	if k > s.maxK {
+		k = s.maxK
+	}
+	if v := params.Get("k"); len(v) > 0 {
+		n, err := typeutil.StrToUint(v)
+		if err != nil || n == 0 || n > uint64(s.maxK) {
+			return httputil.BadRequest(httputil.CodeInvalidParameter,
+				fmt.Sprintf("k must be between 1 and %d", s.maxK))
+		}
+		k = int(n)
+	}
+
+	metric := params.Get("metric")
+	if len(metric) == 0 {
+		metric = score.MetricCosine
+	}
+	if !score.IsMetric(metric) {
+		return httputil.BadRequest(httputil.CodeInvalidParameter,
+			fmt.Sprintf("unknown metric: %s", metric))
+	}
+
+	normalization := params.Get("normalization")
+	if len(normalization) == 0 {
+		normalization = score.NormNone
+	}
+	if !score.IsNormalization(normalization) {
+		return httputil.BadRequest(httputil.CodeInvalidParameter,
+			fmt.Sprintf("unknown normalization: %s", normalization))
+	}
+
+	snap := cache.Current()
+
+	var cols []int
+	if features := params.Get("features"); len(features) > 0 {
+		for _, feat := range strings.Split(features, ",") {
+			col, ok := snap.FeatureColumn(feat)
+			if !ok {
+				return httputil.BadRequest(httputil.CodeUnknownFeature,
+					fmt.Sprintf("non existing feature: %s", feat))
+			}
+			cols = append(cols, col)
+		}
+	}
+
+	row, ok := snap.UserRowByUsername(username)
+	if !ok {
+		return errUserNotFound
+	}
+
+	similar, err := score.Similar(snap, row, metric, normalization, cols, k)
+	if err != nil {
+		return err
+	}
+
+	page := model.SimilarUsers{}
+	if c.Streaming() {
+		page = similar
+	} else if offset := c.Offset(); offset < uint64(len(similar)) {
+		end := offset + c.PerPage
+		if end > uint64(len(similar)) {
+			end = uint64(len(similar))
+		}
+		page = similar[offset:end]
+	}
+	c.SetPageHeaders(w, int64(len(similar)), len(page), nil)
+
+	return c.WriteList(w, page)
+}
@@ -11,7 +11,7 @@ import (
 )
 
 // loadStats loads some database related statistics.
-func loadStats(db *sql.DB) error {
+func loadStats(db *sql.DB, snap *Snapshot) error {
 	var err error
 	var s model.Stats
 
@@ -62,19 +62,20 @@ func loadStats(db *sql.DB) error {
 		return err
 	}
 
-	stats = &s
+	snap.stats = &s
 
 	return nil
 }
 
 // loadFeaturesNames loads the map of features names.
-func loadFeaturesNames(db *sql.DB) error {
+func loadFeaturesNames(db *sql.DB, snap *Snapshot) error {
 	feats := make(map[string]struct{})
 
 	rows, err := db.Query(`SELECT features.name FROM features ORDER BY features.name ASC`)
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var f string
@@ -85,7 +86,7 @@ func loadFeaturesNames(db *sql.DB) error {
 		feats[f] = struct{}{}
 	}
 
-	featuresNames = feats
+	snap.featuresNames = feats
 
 	return nil
 }
@@ -7,92 +7,356 @@
 // To use it, a call to LoadCache() shall be made once at the start of the
 // application. After the data is loaded into memory, it can be accessed with
 // the getters functions.
+// The cache can be reloaded at any time with Reload(). A new snapshot of the
+// data is then built in the background and atomically swapped with the
+// current one once complete. Callers that need several pieces of data which
+// must be consistent with each other (the scores matrix and the users vector
+// for instance) shall get them from the same snapshot, as returned by
+// Current().
 package cache
 
 import (
 	"database/sql"
 	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	mx "code.google.com/p/biogo.matrix"
 
 	"github.com/DevMine/api-server/model"
 )
 
-var (
+// Snapshot holds all the data loaded into memory by a single cache load.
+// A snapshot is never modified once it has been loaded, except for the values
+// derived from it, which are computed lazily (see Derived).
+type Snapshot struct {
 	features      []model.Feature
 	featuresNames map[string]struct{}
 	scoresMatrix  *mx.Sparse
 	stats         *model.Stats
 	usersVector   []model.User
 
+	// userRows maps user IDs to their row in the scores matrix and
+	// usernameRows maps lower case usernames to their row.
+	userRows     map[int64]int
+	usernameRows map[string]int
+
+	// featureColumns maps feature names to their column in the scores
+	// matrix.
+	featureColumns map[string]int
+
+	// columnStats holds the statistics of each column of the scores matrix.
+	columnStats []ColumnStats
+
+	// profiles holds the profile of the user of each row of the scores
+	// matrix. organizationRows and languageRows map lower case organization
+	// logins and languages to the rows of the corresponding users.
+	profiles         []Profile
+	organizationRows map[string][]int
+	languageRows     map[string][]int
+
+	// nonZeroScores is the number of non-zero entries of the scores matrix.
+	nonZeroScores int
+
+	generation   uint64
+	loadedAt     time.Time
+	loadDuration time.Duration
+
+	// derived holds the values derived from the snapshot, by key.
+	derivedMu sync.Mutex
+	derived   map[string]*derivedValue
+}
+
+var (
+	// current holds a pointer to the current Snapshot.
+	current atomic.Value
+
+	// db is the database session used to (re)load the cache.
+	db *sql.DB
+
+	// reloading is set to 1 while a reload is in progress.
+	reloading int32
+
+	// mu serializes generation numbering.
+	mu         sync.Mutex
+	generation uint64
+
 	errCacheNotLoaded = errors.New("cache not loaded")
+
+	// ErrReloadInProgress is returned by Reload when the cache is already
+	// being reloaded.
+	ErrReloadInProgress = errors.New("cache reload already in progress")
 )
 
-// LoadCache loads all cacheable data into memory.
-func LoadCache(db *sql.DB) error {
-	if err := loadStats(db); err != nil {
-		return err
+// LoadCache loads all cacheable data into memory. The database session is
+// kept to be used by subsequent calls to Reload.
+func LoadCache(session *sql.DB) error {
+	if session == nil {
+		return errors.New("database session cannot be nil")
 	}
+	db = session
 
-	if err := loadFeatures(db); err != nil {
-		return err
+	return Reload()
+}
+
+// Reload loads a new snapshot of all cacheable data into memory and swaps it
+// with the current one once fully loaded. Until then, the previous snapshot
+// keeps being served. If a reload is already in progress, ErrReloadInProgress
+// is returned.
+func Reload() error {
+	if db == nil {
+		return errCacheNotLoaded
 	}
 
-	if err := loadFeaturesNames(db); err != nil {
-		return err
+	if !atomic.CompareAndSwapInt32(&reloading, 0, 1) {
+		return ErrReloadInProgress
+	}
+
+	return reload()
+}
+
+// ReloadAsync works like Reload except that the new snapshot is loaded in a
+// separate goroutine. The result of the reload is sent on the returned
+// channel.
+func ReloadAsync() (<-chan error, error) {
+	if db == nil {
+		return nil, errCacheNotLoaded
+	}
+
+	if !atomic.CompareAndSwapInt32(&reloading, 0, 1) {
+		return nil, ErrReloadInProgress
 	}
 
-	if err := loadScoresAndUsers(db); err != nil {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- reload()
+	}()
+
+	return errc, nil
+}
+
+// reload does the actual reload work. The caller must have set the reloading
+// flag beforehand.
+func reload() error {
+	defer atomic.StoreInt32(&reloading, 0)
+
+	tic := time.Now()
+	s, err := load(db)
+	if err != nil {
 		return err
 	}
+	s.loadedAt = time.Now()
+	s.loadDuration = s.loadedAt.Sub(tic)
+
+	swap(s)
 
 	return nil
 }
 
-// GetStats provides database statistics.
-func GetStats() model.Stats {
-	if stats == nil {
-		panic(errCacheNotLoaded)
+// swap gives the next generation number to snapshot s and makes it the
+// current one.
+func swap(s *Snapshot) {
+	mu.Lock()
+	generation++
+	s.generation = generation
+	current.Store(s)
+	mu.Unlock()
+}
+
+// load creates a new snapshot from the database.
+func load(db *sql.DB) (*Snapshot, error) {
+	s := new(Snapshot)
+
+	if err := loadStats(db, s); err != nil {
+		return nil, err
 	}
-	return *stats
+
+	if err := loadFeatures(db, s); err != nil {
+		return nil, err
+	}
+
+	if err := loadFeaturesNames(db, s); err != nil {
+		return nil, err
+	}
+
+	if err := loadScoresAndUsers(db, s); err != nil {
+		return nil, err
+	}
+
+	s.index()
+
+	if err := loadProfiles(db, s); err != nil {
+		return nil, err
+	}
+	s.indexProfiles()
+
+	return s, nil
 }
 
-// GetFeatures returns a slice containing all features.
-func GetFeatures() []model.Feature {
-	if features == nil {
-		panic(errCacheNotLoaded)
+// index builds the indexes of a snapshot once its data is loaded.
+func (s *Snapshot) index() {
+	s.userRows = make(map[int64]int, len(s.usersVector))
+	s.usernameRows = make(map[string]int, len(s.usersVector))
+	for i, u := range s.usersVector {
+		if u.ID != nil {
+			s.userRows[*u.ID] = i
+		}
+		if u.Username != nil {
+			s.usernameRows[strings.ToLower(*u.Username)] = i
+		}
 	}
-	return features
+
+	s.featureColumns = make(map[string]int, len(s.features))
+	for j, f := range s.features {
+		s.featureColumns[*f.Name] = j
+	}
+
+	s.columnStats = computeColumnStats(s.scoresMatrix)
 }
 
-// GetFeaturesNames returns a map of features names. It can be used to check in
-// O(1) if a feature exists by providing its name as a key to the map.
-func GetFeaturesNames() map[string]struct{} {
-	if featuresNames == nil {
+// Current returns the snapshot currently in use.
+func Current() *Snapshot {
+	s := Latest()
+	if s == nil {
 		panic(errCacheNotLoaded)
 	}
-	return featuresNames
+	return s
+}
+
+// Latest returns the snapshot currently in use or nil if the cache is not
+// loaded yet. Unlike Current, it does not panic.
+func Latest() *Snapshot {
+	s, _ := current.Load().(*Snapshot)
+	return s
+}
+
+// Status returns information about the current state of the cache.
+func Status() model.CacheStatus {
+	var cs model.CacheStatus
+	cs.Reloading = atomic.LoadInt32(&reloading) == 1
+
+	if s := Latest(); s != nil {
+		loadedAt := s.loadedAt
+		cs.Generation = s.generation
+		cs.LoadedAt = &loadedAt
+		cs.LoadDuration = s.loadDuration.Seconds()
+	}
+
+	return cs
+}
+
+// Generation returns the generation number of the snapshot. Each successful
+// load of the cache increments the generation number.
+func (s *Snapshot) Generation() uint64 {
+	return s.generation
+}
+
+// LoadedAt returns the time at which the snapshot finished loading.
+func (s *Snapshot) LoadedAt() time.Time {
+	return s.loadedAt
 }
 
-// GetScoresMatrix returns the scores matrix from cache.
+// LoadDuration returns the time it took to load the snapshot.
+func (s *Snapshot) LoadDuration() time.Duration {
+	return s.loadDuration
+}
+
+// NonZeroScores returns the number of non-zero entries of the scores matrix.
+func (s *Snapshot) NonZeroScores() int {
+	return s.nonZeroScores
+}
+
+// Stats provides database statistics.
+func (s *Snapshot) Stats() model.Stats {
+	return *s.stats
+}
+
+// Features returns a slice containing all features.
+func (s *Snapshot) Features() []model.Feature {
+	return s.features
+}
+
+// FeaturesNames returns a map of features names. It can be used to check in
+// O(1) if a feature exists by providing its name as a key to the map.
+func (s *Snapshot) FeaturesNames() map[string]struct{} {
+	return s.featuresNames
+}
+
+// ScoresMatrix returns the scores matrix.
 // Each row of the matrix corresponds to a user whereas each column corresponds
 // to a feature. The scores matrix is closely related to the users vector.
 // Row 'i' of the scores matrix corresponds to the scores for each feature for
-// the user at row 'i' in the users vector.
-func GetScoresMatrix() *mx.Sparse {
-	if scoresMatrix == nil {
-		panic(errCacheNotLoaded)
-	}
-	return scoresMatrix
+// the user at row 'i' in the users vector of the same snapshot.
+func (s *Snapshot) ScoresMatrix() *mx.Sparse {
+	return s.scoresMatrix
 }
 
-// GetUsersVector returns the vector of users from cache.
+// UsersVector returns the vector of users.
 // Important: the vector is sorted, matching the rows of the scores matrix.
 // In other words, user at position 'i' in the slice corresponds as the scores
-// for each feature at row 'i' of the scores matrix.
+// for each feature at row 'i' of the scores matrix of the same snapshot.
+func (s *Snapshot) UsersVector() []model.User {
+	return s.usersVector
+}
+
+// UserRow returns the row of the scores matrix corresponding to the user with
+// the given ID and whether the user is part of the snapshot.
+func (s *Snapshot) UserRow(id int64) (int, bool) {
+	row, ok := s.userRows[id]
+	return row, ok
+}
+
+// UserRowByUsername returns the row of the scores matrix corresponding to the
+// user with the given username, compared case insensitively, and whether the
+// user is part of the snapshot.
+func (s *Snapshot) UserRowByUsername(username string) (int, bool) {
+	row, ok := s.usernameRows[strings.ToLower(username)]
+	return row, ok
+}
+
+// FeatureColumn returns the column of the scores matrix corresponding to the
+// feature with the given name and whether the feature exists.
+func (s *Snapshot) FeatureColumn(name string) (int, bool) {
+	col, ok := s.featureColumns[name]
+	return col, ok
+}
+
+// ColumnStats returns the statistics of each column of the scores matrix,
+// that is of the scores of each feature.
+func (s *Snapshot) ColumnStats() []ColumnStats {
+	return s.columnStats
+}
+
+// GetStats provides database statistics from the current snapshot.
+func GetStats() model.Stats {
+	return Current().Stats()
+}
+
+// GetFeatures returns a slice containing all features from the current
+// snapshot.
+func GetFeatures() []model.Feature {
+	return Current().Features()
+}
+
+// GetFeaturesNames returns a map of features names from the current snapshot.
+// It can be used to check in O(1) if a feature exists by providing its name as
+// a key to the map.
+func GetFeaturesNames() map[string]struct{} {
+	return Current().FeaturesNames()
+}
+
+// GetScoresMatrix returns the scores matrix from the current snapshot.
+// Since the cache may be reloaded between two calls, use Current() instead
+// when the users vector is needed as well.
+func GetScoresMatrix() *mx.Sparse {
+	return Current().ScoresMatrix()
+}
+
+// GetUsersVector returns the vector of users from the current snapshot.
+// Since the cache may be reloaded between two calls, use Current() instead
+// when the scores matrix is needed as well.
 func GetUsersVector() []model.User {
-	if usersVector == nil {
-		panic(errCacheNotLoaded)
-	}
-	return usersVector
+	return Current().UsersVector()
 }
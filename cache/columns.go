@@ -0,0 +1,94 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"math"
+	"sort"
+
+	mx "code.google.com/p/biogo.matrix"
+)
+
+// ColumnStats holds statistics about a column of the scores matrix, that is
+// about the scores of all users for a feature.
+type ColumnStats struct {
+	Min    float64
+	Max    float64
+	Mean   float64
+	StdDev float64
+
+	// sorted holds the scores of the column, in increasing order.
+	sorted []float64
+}
+
+// computeColumnStats computes the statistics of each column of matrix m.
+func computeColumnStats(m mx.Matrix) []ColumnStats {
+	rows, cols := m.Dims()
+	stats := make([]ColumnStats, cols)
+	if rows == 0 {
+		return stats
+	}
+
+	for j := range stats {
+		cs := &stats[j]
+		cs.sorted = make([]float64, rows)
+
+		var sum float64
+		for i := 0; i < rows; i++ {
+			v := m.At(i, j)
+			cs.sorted[i] = v
+			sum += v
+		}
+		sort.Float64s(cs.sorted)
+
+		cs.Min = cs.sorted[0]
+		cs.Max = cs.sorted[rows-1]
+		cs.Mean = sum / float64(rows)
+
+		var sqDiff float64
+		for _, v := range cs.sorted {
+			sqDiff += (v - cs.Mean) * (v - cs.Mean)
+		}
+		cs.StdDev = math.Sqrt(sqDiff / float64(rows))
+	}
+
+	return stats
+}
+
+// Count returns the number of scores of the column.
+func (cs ColumnStats) Count() int {
+	return len(cs.sorted)
+}
+
+// Median returns the median of the scores of the column.
+func (cs ColumnStats) Median() float64 {
+	n := len(cs.sorted)
+	if n == 0 {
+		return 0
+	}
+	if n%2 == 1 {
+		return cs.sorted[n/2]
+	}
+	return (cs.sorted[n/2-1] + cs.sorted[n/2]) / 2
+}
+
+// Below returns the number of scores of the column strictly lower than v and
+// the number of scores equal to v.
+func (cs ColumnStats) Below(v float64) (below, equal int) {
+	below = sort.SearchFloat64s(cs.sorted, v)
+	upTo := sort.Search(len(cs.sorted), func(i int) bool { return cs.sorted[i] > v })
+	return below, upTo - below
+}
+
+// Percentile returns the percentile rank of score v in the column, between 0
+// and 1: the fraction of scores lower than v, counting half of the scores
+// equal to v.
+func (cs ColumnStats) Percentile(v float64) float64 {
+	if len(cs.sorted) == 0 {
+		return 0
+	}
+	below, equal := cs.Below(v)
+	return (float64(below) + float64(equal)/2) / float64(len(cs.sorted))
+}
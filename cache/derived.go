@@ -0,0 +1,37 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import "sync"
+
+// derivedValue is a value derived from a snapshot, computed once.
+type derivedValue struct {
+	once  sync.Once
+	value interface{}
+	err   error
+}
+
+// Derived returns the value derived from the snapshot under the given key,
+// computing it with compute on first use. Each value is computed once per
+// snapshot: concurrent callers asking for the same key wait for the first
+// computation to complete. Values are dropped along with the snapshot when
+// the cache is reloaded.
+func (s *Snapshot) Derived(key string, compute func() (interface{}, error)) (interface{}, error) {
+	s.derivedMu.Lock()
+	if s.derived == nil {
+		s.derived = make(map[string]*derivedValue)
+	}
+	dv, ok := s.derived[key]
+	if !ok {
+		dv = new(derivedValue)
+		s.derived[key] = dv
+	}
+	s.derivedMu.Unlock()
+
+	dv.once.Do(func() {
+		dv.value, dv.err = compute()
+	})
+	return dv.value, dv.err
+}
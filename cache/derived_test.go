@@ -0,0 +1,49 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDerived(t *testing.T) {
+	var calls int32
+	compute := func(v int) func() (interface{}, error) {
+		return func() (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			return v, nil
+		}
+	}
+
+	// values of two snapshots in use at the same time, as during a reload,
+	// do not evict each other
+	old, cur := new(Snapshot), new(Snapshot)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if v, _ := old.Derived("key", compute(1)); v != 1 {
+				t.Errorf("got %v for the old snapshot, want 1", v)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if v, _ := cur.Derived("key", compute(2)); v != 2 {
+				t.Errorf("got %v for the current snapshot, want 2", v)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v, _ := cur.Derived("other", compute(3)); v != 3 {
+		t.Errorf("got %v for another key, want 3", v)
+	}
+	if calls != 3 {
+		t.Errorf("got %d computations, want 3", calls)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	mx "code.google.com/p/biogo.matrix"
+
+	"github.com/DevMine/api-server/model"
+)
+
+// Fixture holds the data of a snapshot which is not loaded from a database,
+// for instance to run the server with fixtures in unit tests.
+type Fixture struct {
+	Stats model.Stats
+
+	// Features are the features, sorted by name.
+	Features []model.Feature
+
+	// Users are the users having scores.
+	Users []model.User
+
+	// Scores holds the scores of each user, in the order of Users, for each
+	// feature, in the order of Features.
+	Scores [][]float64
+
+	// Profiles holds the profile of each user, in the order of Users. It may
+	// be empty.
+	Profiles []Profile
+}
+
+// LoadFixture builds a snapshot from fixture data and swaps it with the
+// current one, like Reload does.
+func LoadFixture(f Fixture) error {
+	if !sort.SliceIsSorted(f.Features, func(i, j int) bool {
+		return *f.Features[i].Name < *f.Features[j].Name
+	}) {
+		return errors.New("fixture features must be sorted by name")
+	}
+	if len(f.Scores) != len(f.Users) {
+		return errors.New("fixture scores must have one row per user")
+	}
+	if len(f.Profiles) != 0 && len(f.Profiles) != len(f.Users) {
+		return errors.New("fixture profiles must have one profile per user")
+	}
+
+	s := &Snapshot{
+		features:      f.Features,
+		featuresNames: make(map[string]struct{}, len(f.Features)),
+		stats:         &f.Stats,
+		usersVector:   f.Users,
+		profiles:      f.Profiles,
+	}
+	for _, feat := range f.Features {
+		s.featuresNames[*feat.Name] = struct{}{}
+	}
+	for _, row := range f.Scores {
+		if len(row) != len(f.Features) {
+			return errors.New("fixture scores must have one column per feature")
+		}
+		for _, v := range row {
+			if v != 0 {
+				s.nonZeroScores++
+			}
+		}
+	}
+
+	scores, err := mx.NewSparse(f.Scores)
+	if err != nil {
+		return err
+	}
+	s.scoresMatrix = scores
+
+	s.index()
+	if len(s.profiles) == 0 {
+		s.profiles = make([]Profile, len(f.Users))
+	}
+	s.indexProfiles()
+
+	s.loadedAt = time.Now()
+	swap(s)
+
+	return nil
+}
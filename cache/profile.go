@@ -0,0 +1,162 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+)
+
+// Profile holds the attributes of the GitHub profile of a user which search
+// queries can filter on. Strings are in lower case.
+type Profile struct {
+	Location  string
+	Company   string
+	Hireable  *bool
+	Followers *int64
+
+	// Organizations holds the logins of the organizations the user is a
+	// member of.
+	Organizations []string
+
+	// Languages holds the primary languages of the repositories associated to
+	// the user.
+	Languages []string
+}
+
+// loadProfiles loads the profile of each user of the users vector. It must
+// be called once the snapshot is indexed.
+func loadProfiles(db *sql.DB, snap *Snapshot) error {
+	snap.profiles = make([]Profile, len(snap.usersVector))
+
+	rows, err := db.Query(
+		`SELECT ghu.user_id, ghu.location, ghu.company, ghu.hireable, ghu.followers_count
+         FROM gh_users AS ghu`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID int64
+		var location, company sql.NullString
+		var hireable sql.NullBool
+		var followers sql.NullInt64
+		if err := rows.Scan(&userID, &location, &company, &hireable, &followers); err != nil {
+			return err
+		}
+
+		row, ok := snap.userRows[userID]
+		if !ok {
+			continue
+		}
+		p := &snap.profiles[row]
+		p.Location = strings.ToLower(location.String)
+		p.Company = strings.ToLower(company.String)
+		if hireable.Valid {
+			p.Hireable = &hireable.Bool
+		}
+		if followers.Valid {
+			p.Followers = &followers.Int64
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	err = loadProfileValues(db, snap,
+		`SELECT ghu.user_id, gho.login
+         FROM gh_users_organizations AS ghuo
+         JOIN gh_users AS ghu ON ghuo.gh_user_id = ghu.id
+         JOIN gh_organizations AS gho ON ghuo.gh_organization_id = gho.id`,
+		func(p *Profile, v string) { p.Organizations = append(p.Organizations, v) })
+	if err != nil {
+		return err
+	}
+
+	return loadProfileValues(db, snap,
+		`SELECT DISTINCT ur.user_id, r.primary_language
+         FROM users_repositories AS ur
+         JOIN repositories AS r ON ur.repository_id = r.id
+         WHERE r.primary_language IS NOT NULL`,
+		func(p *Profile, v string) { p.Languages = append(p.Languages, v) })
+}
+
+// loadProfileValues runs a query selecting user IDs along with a value and
+// adds the values, in lower case, to the profiles of the users.
+func loadProfileValues(db *sql.DB, snap *Snapshot, query string, add func(p *Profile, v string)) error {
+	rows, err := db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID int64
+		var v string
+		if err := rows.Scan(&userID, &v); err != nil {
+			return err
+		}
+
+		if row, ok := snap.userRows[userID]; ok {
+			add(&snap.profiles[row], strings.ToLower(v))
+		}
+	}
+
+	return rows.Err()
+}
+
+// indexProfiles builds the indexes of the rows of the users by organization
+// and by language.
+func (s *Snapshot) indexProfiles() {
+	s.organizationRows = make(map[string][]int)
+	s.languageRows = make(map[string][]int)
+
+	for row, p := range s.profiles {
+		for _, org := range p.Organizations {
+			s.organizationRows[org] = append(s.organizationRows[org], row)
+		}
+		for _, lang := range p.Languages {
+			s.languageRows[lang] = append(s.languageRows[lang], row)
+		}
+	}
+}
+
+// Profile returns the profile of the user at the given row of the scores
+// matrix.
+func (s *Snapshot) Profile(row int) *Profile {
+	return &s.profiles[row]
+}
+
+// RowsByOrganization returns the rows, in increasing order, of the users
+// member of at least one of the given organizations, compared case
+// insensitively.
+func (s *Snapshot) RowsByOrganization(orgs ...string) []int {
+	return unionRows(s.organizationRows, orgs)
+}
+
+// RowsByLanguage returns the rows, in increasing order, of the users
+// associated to at least one repository whose primary language is one of the
+// given languages, compared case insensitively.
+func (s *Snapshot) RowsByLanguage(langs ...string) []int {
+	return unionRows(s.languageRows, langs)
+}
+
+// unionRows returns the union of the rows of the given keys of index.
+func unionRows(index map[string][]int, keys []string) []int {
+	seen := make(map[int]bool)
+	var rows []int
+	for _, key := range keys {
+		for _, row := range index[strings.ToLower(key)] {
+			if !seen[row] {
+				seen[row] = true
+				rows = append(rows, row)
+			}
+		}
+	}
+	sort.Ints(rows)
+	return rows
+}
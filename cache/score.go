@@ -6,6 +6,7 @@ package cache
 
 import (
 	"database/sql"
+	"fmt"
 	"strings"
 
 	mx "code.google.com/p/biogo.matrix"
@@ -15,7 +16,7 @@ import (
 )
 
 // loadFeatures loads all features into memory.
-func loadFeatures(db *sql.DB) error {
+func loadFeatures(db *sql.DB, snap *Snapshot) error {
 	rows, err := db.Query(
 		`SELECT f.id, f.name, f.category, f.default_weight
          FROM features AS f
@@ -23,6 +24,7 @@ func loadFeatures(db *sql.DB) error {
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
 	var feats []model.Feature
 	for rows.Next() {
@@ -33,53 +35,95 @@ func loadFeatures(db *sql.DB) error {
 		feats = append(feats, f)
 	}
 
-	features = feats
+	snap.features = feats
 
 	return nil
 }
 
 // loadScoresAndUsers loads the scores matrix and the users vector into memory.
-func loadScoresAndUsers(db *sql.DB) error {
+// The features must have been loaded beforehand.
+func loadScoresAndUsers(db *sql.DB, snap *Snapshot) error {
 
 	var nbUsers uint
 	if err := db.QueryRow(`SELECT COUNT(users.id) FROM users`).Scan(&nbUsers); err != nil {
 		return err
 	}
 
-	var nbFeats uint
-	if err := db.QueryRow(`SELECT COUNT(features.id) FROM features`).Scan(&nbFeats); err != nil {
-		return err
-	}
-
+	// the name of the feature of each score is aggregated along with the
+	// score, in the same order, so that scores can be put in the column of
+	// their feature
 	rows, err := db.Query(
 		`SELECT users.id, users.username, users.name, users.email,
-				array_to_string(array_agg(s.score ORDER BY s.feature_id), ' ')
+				array_to_string(array_agg(f.name ORDER BY f.name), ' '),
+				array_to_string(array_agg(s.score ORDER BY f.name), ' ')
 			FROM scores AS s
             JOIN users ON s.user_id=users.id
+            JOIN features AS f ON s.feature_id=f.id
 			GROUP BY users.id`)
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 
-	m := make([][]float64, nbUsers)
-	users := make([]model.User, nbUsers)
+	return readScoresAndUsers(rows, nbUsers, snap)
+}
+
+// scoreRows is implemented by *sql.Rows.
+type scoreRows interface {
+	Next() bool
+	Scan(dest ...interface{}) error
+	Err() error
+}
+
+// readScoresAndUsers reads the scores matrix and the users vector from rows
+// selected by loadScoresAndUsers. Each row holds a user, the names of the
+// features the user has a score for and the corresponding scores, separated
+// by spaces. Column 'j' of the scores matrix is made of the scores for feature
+// 'j' of the snapshot, whatever the order of the scores in the rows.
+// Users without any score are not part of the rows, hence of the snapshot.
+func readScoresAndUsers(rows scoreRows, nbUsers uint, snap *Snapshot) error {
+	columns := make(map[string]int, len(snap.features))
+	for j, f := range snap.features {
+		columns[*f.Name] = j
+	}
+
+	m := make([][]float64, 0, nbUsers)
+	users := make([]model.User, 0, nbUsers)
 
-	for i := 0; rows.Next(); i++ {
+	for rows.Next() {
 		var u model.User
-		var scores string
-		m[i] = make([]float64, nbFeats)
+		var names, scores string
 
-		if err := rows.Scan(&u.ID, &u.Username, &u.Name, &u.Email, &scores); err != nil {
+		if err := rows.Scan(&u.ID, &u.Username, &u.Name, &u.Email, &names, &scores); err != nil {
 			return err
 		}
 
-		users[i] = u
-		for j, s := range strings.Split(scores, " ") {
-			if m[i][j], err = typeutil.StrToFloat(s); err != nil {
+		row := make([]float64, len(snap.features))
+		features, values := strings.Fields(names), strings.Fields(scores)
+		if len(features) != len(values) {
+			return fmt.Errorf("user %d: %d scores for %d features", *u.ID, len(values), len(features))
+		}
+		for k, name := range features {
+			j, ok := columns[name]
+			if !ok {
+				return fmt.Errorf("user %d: score for unknown feature %s", *u.ID, name)
+			}
+
+			v, err := typeutil.StrToFloat(values[k])
+			if err != nil {
 				return err
 			}
+			row[j] = v
+			if v != 0 {
+				snap.nonZeroScores++
+			}
 		}
+
+		m = append(m, row)
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return err
 	}
 
 	scores, err := mx.NewSparse(m)
@@ -87,8 +131,8 @@ func loadScoresAndUsers(db *sql.DB) error {
 		return err
 	}
 
-	scoresMatrix = scores
-	usersVector = users
+	snap.scoresMatrix = scores
+	snap.usersVector = users
 
 	return nil
 }
@@ -0,0 +1,109 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/DevMine/api-server/model"
+)
+
+// fakeScoreRows are rows of users with their feature names and scores, as
+// selected by loadScoresAndUsers.
+type fakeScoreRows struct {
+	rows [][]interface{}
+	next int
+}
+
+func (r *fakeScoreRows) Next() bool {
+	r.next++
+	return r.next <= len(r.rows)
+}
+
+func (r *fakeScoreRows) Scan(dest ...interface{}) error {
+	row := r.rows[r.next-1]
+	if len(dest) != len(row) {
+		return errors.New("wrong number of columns")
+	}
+	for i, v := range row {
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(v))
+	}
+	return nil
+}
+
+func (r *fakeScoreRows) Err() error { return nil }
+
+func scoreRow(id int64, username, features, scores string) []interface{} {
+	return []interface{}{&id, &username, (*string)(nil), (*string)(nil), features, scores}
+}
+
+func TestReadScoresAndUsers(t *testing.T) {
+	var features []model.Feature
+	for _, name := range []string{"followers_count", "hireable", "stars_avg"} {
+		name := name
+		features = append(features, model.Feature{Name: &name})
+	}
+
+	// scores are given out of feature order and some are missing
+	rows := &fakeScoreRows{rows: [][]interface{}{
+		scoreRow(1, "alice", "stars_avg followers_count hireable", "3 10 1"),
+		scoreRow(2, "bob", "hireable stars_avg", "1 4.5"),
+		scoreRow(5, "carol", "followers_count", "7"),
+	}}
+
+	snap := &Snapshot{features: features}
+	if err := readScoresAndUsers(rows, 3, snap); err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]float64{
+		{10, 1, 3},
+		{0, 1, 4.5},
+		{7, 0, 0},
+	}
+	for i := range want {
+		for j := range want[i] {
+			if v := snap.scoresMatrix.At(i, j); v != want[i][j] {
+				t.Errorf("score of user %d for %s: got %v, want %v",
+					i, *features[j].Name, v, want[i][j])
+			}
+		}
+	}
+
+	var usernames []string
+	for _, u := range snap.usersVector {
+		usernames = append(usernames, *u.Username)
+	}
+	if !reflect.DeepEqual(usernames, []string{"alice", "bob", "carol"}) {
+		t.Errorf("got users %v, want alice, bob and carol", usernames)
+	}
+	if snap.nonZeroScores != 6 {
+		t.Errorf("got %d non-zero scores, want 6", snap.nonZeroScores)
+	}
+}
+
+func TestReadScoresAndUsersErrors(t *testing.T) {
+	name := "stars_avg"
+	features := []model.Feature{{Name: &name}}
+
+	tests := []struct {
+		name string
+		row  []interface{}
+	}{
+		{"unknown feature", scoreRow(1, "alice", "forks_count", "1")},
+		{"missing score", scoreRow(1, "alice", "stars_avg", "")},
+		{"invalid score", scoreRow(1, "alice", "stars_avg", "high")},
+	}
+
+	for _, tt := range tests {
+		snap := &Snapshot{features: features}
+		rows := &fakeScoreRows{rows: [][]interface{}{tt.row}}
+		if err := readScoresAndUsers(rows, 1, snap); err == nil {
+			t.Errorf("%s: got no error", tt.name)
+		}
+	}
+}
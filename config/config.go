@@ -22,10 +22,25 @@ var sslModes = map[string]bool{
 	"verify-full": true,
 }
 
+// normalizations corresponds to the normalization modes of the scores
+// available for search queries.
+var normalizations = map[string]bool{
+	"none":            true,
+	"min-max":         true,
+	"z-score":         true,
+	"rank-percentile": true,
+	"log":             true,
+}
+
 // Config is the main configuration structure.
 type Config struct {
-	Database DatabaseConfig `json:"database"`
-	Server   ServerConfig   `json:"server"`
+	Database    DatabaseConfig    `json:"database"`
+	Server      ServerConfig      `json:"server"`
+	Cache       CacheConfig       `json:"cache"`
+	Metrics     MetricsConfig     `json:"metrics"`
+	Compression CompressionConfig `json:"compression"`
+	RateLimit   RateLimitConfig   `json:"rate_limit"`
+	Search      SearchConfig      `json:"search"`
 }
 
 // DatabaseConfig is a configuration for PostgreSQL database connection
@@ -47,6 +62,127 @@ type ServerConfig struct {
 	HostName   string `json:"hostname"`
 	Port       int    `json:"port"`
 	EnableCors bool   `json:"enable_cors"`
+
+	// Timeouts, in seconds, of the HTTP server. A value of 0 means no
+	// timeout.
+	ReadTimeout  int `json:"read_timeout"`
+	WriteTimeout int `json:"write_timeout"`
+	IdleTimeout  int `json:"idle_timeout"`
+
+	// ShutdownTimeout is the maximum amount of time, in seconds, to wait for
+	// in-flight requests to complete when the server is asked to stop.
+	// A value of 0 means waiting until all requests are done.
+	ShutdownTimeout int `json:"shutdown_timeout"`
+
+	// AdminToken is the token that must be provided to access the /admin
+	// routes. When empty, the /admin routes are disabled.
+	AdminToken string `json:"admin_token"`
+
+	// TLSCertFile and TLSKeyFile are the paths to the PEM encoded
+	// certificate and private key of the server. When both are set, the
+	// server serves HTTPS, with HTTP/2 support, instead of plain HTTP.
+	TLSCertFile string `json:"tls_cert_file"`
+	TLSKeyFile  string `json:"tls_key_file"`
+
+	// TLSMinVersion is the minimum TLS version accepted by the server. Can
+	// take values: 1.0, 1.1, 1.2 or 1.3. Defaults to 1.2.
+	TLSMinVersion string `json:"tls_min_version"`
+
+	// TLSClientCAFile is the path to a PEM encoded bundle of certificate
+	// authorities. When set, clients must present a certificate signed by
+	// one of these authorities (mutual TLS).
+	TLSClientCAFile string `json:"tls_client_ca_file"`
+
+	// HTTPRedirectPort is the port on which to listen for plain HTTP
+	// requests and redirect them to HTTPS. A value of 0 disables the
+	// redirection.
+	HTTPRedirectPort int `json:"http_redirect_port"`
+}
+
+// CacheConfig is a configuration for the in-memory cache.
+type CacheConfig struct {
+	// ReloadInterval is the interval, in seconds, at which the cache is
+	// periodically reloaded. A value of 0 disables periodic reloads.
+	ReloadInterval int `json:"reload_interval"`
+
+	// HTTPCacheControl is the Cache-Control header value of the responses
+	// computed from cached data. Defaults to "no-cache", which lets clients
+	// store responses as long as they revalidate them.
+	HTTPCacheControl string `json:"http_cache_control"`
+}
+
+// MetricsConfig is a configuration for the metrics endpoint.
+type MetricsConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// HostName and Port specify a separate address on which to serve the
+	// metrics. When Port is 0, the metrics are served by the API server
+	// under the /metrics route.
+	HostName string `json:"hostname"`
+	Port     int    `json:"port"`
+}
+
+// CompressionConfig is a configuration for the compression of responses.
+type CompressionConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// MinSize is the minimum size, in bytes, of the responses to compress.
+	MinSize int `json:"min_size"`
+}
+
+// RateLimitConfig is a configuration for the rate limiting of clients.
+type RateLimitConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// Default is the limit applied to the routes for which no specific
+	// limit is configured.
+	Default RateLimit `json:"default"`
+
+	// Routes holds per route limits, keyed by path prefix (eg "/search").
+	// When several prefixes match a request path, the longest one is used.
+	Routes map[string]RateLimit `json:"routes"`
+
+	// APIKeys are the keys clients may provide with the X-API-Key header.
+	// Clients providing a valid key are limited by key rather than by IP
+	// address.
+	APIKeys []string `json:"api_keys"`
+
+	// TrustProxy specifies whether to identify clients by the last address
+	// of the X-Forwarded-For header, when the server runs behind a reverse
+	// proxy. The last address is the one added by the proxy, the others can
+	// be forged by clients.
+	TrustProxy bool `json:"trust_proxy"`
+}
+
+// RateLimit is a limit of Requests requests per Period seconds, with bursts
+// of at most Burst requests. Burst defaults to Requests. A limit of 0
+// requests means no limit.
+type RateLimit struct {
+	Requests int `json:"requests"`
+	Period   int `json:"period"`
+	Burst    int `json:"burst"`
+}
+
+// SearchConfig is a configuration for the search queries.
+type SearchConfig struct {
+	// MaxResults is the maximum number of ranked results that clients can
+	// reach by paginating search results. Defaults to 1000.
+	MaxResults int `json:"max_results"`
+
+	// Normalization is the normalization mode of the scores used by queries
+	// which do not specify any. Can take values: none, min-max, z-score,
+	// rank-percentile or log. Defaults to none.
+	Normalization string `json:"normalization"`
+
+	// ResultCacheSize is the number of queries whose ranked results are kept
+	// in memory, until the cache is reloaded. A value of 0 disables the
+	// result cache.
+	ResultCacheSize int `json:"result_cache_size"`
+
+	// MaxSimilar is the maximum number of similar users that can be
+	// requested with the "k" parameter of the similar users route. Defaults
+	// to 100.
+	MaxSimilar int `json:"max_similar"`
 }
 
 // ReadConfig reads a JSON formatted configuration file, verifies the values
@@ -82,6 +218,31 @@ func (c Config) verify() error {
 		return err
 	}
 
+	err = c.Cache.verify()
+	if err != nil {
+		return err
+	}
+
+	err = c.Metrics.verify()
+	if err != nil {
+		return err
+	}
+
+	err = c.Compression.verify()
+	if err != nil {
+		return err
+	}
+
+	err = c.RateLimit.verify()
+	if err != nil {
+		return err
+	}
+
+	err = c.Search.verify()
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -118,5 +279,108 @@ func (sc ServerConfig) verify() error {
 		return errors.New("server port must be greater than 0")
 	}
 
+	if sc.ReadTimeout < 0 || sc.WriteTimeout < 0 || sc.IdleTimeout < 0 {
+		return errors.New("server timeouts cannot be negative")
+	}
+
+	if sc.ShutdownTimeout < 0 {
+		return errors.New("server shutdown timeout cannot be negative")
+	}
+
+	if (len(sc.TLSCertFile) == 0) != (len(sc.TLSKeyFile) == 0) {
+		return errors.New("server TLS certificate and key must be specified together")
+	}
+
+	if !sc.TLSEnabled() {
+		if len(sc.TLSClientCAFile) > 0 {
+			return errors.New("server TLS client CA requires a certificate and a key")
+		}
+		if sc.HTTPRedirectPort != 0 {
+			return errors.New("server HTTP redirection requires a certificate and a key")
+		}
+		return nil
+	}
+
+	if sc.HTTPRedirectPort < 0 || sc.HTTPRedirectPort == sc.Port {
+		return errors.New("server HTTP redirect port must be positive and differ from the server port")
+	}
+
+	if _, err := sc.TLSConfig(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (cc CacheConfig) verify() error {
+	if cc.ReloadInterval < 0 {
+		return errors.New("cache reload interval cannot be negative")
+	}
+
+	return nil
+}
+
+func (mc MetricsConfig) verify() error {
+	if mc.Port < 0 {
+		return errors.New("metrics port cannot be negative")
+	}
+
+	return nil
+}
+
+func (cc CompressionConfig) verify() error {
+	if cc.MinSize < 0 {
+		return errors.New("compression minimum size cannot be negative")
+	}
+
+	return nil
+}
+
+func (rc RateLimitConfig) verify() error {
+	if err := rc.Default.verify(); err != nil {
+		return err
+	}
+
+	for prefix, rl := range rc.Routes {
+		if !strings.HasPrefix(prefix, "/") {
+			return errors.New("rate limit route prefixes must start with /")
+		}
+		if err := rl.verify(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (rl RateLimit) verify() error {
+	if rl.Requests < 0 || rl.Burst < 0 {
+		return errors.New("rate limit requests and burst cannot be negative")
+	}
+
+	if rl.Requests > 0 && rl.Period <= 0 {
+		return errors.New("rate limit period must be greater than 0")
+	}
+
+	return nil
+}
+
+func (sc SearchConfig) verify() error {
+	if sc.MaxResults < 0 {
+		return errors.New("search maximum number of results cannot be negative")
+	}
+
+	if _, ok := normalizations[sc.Normalization]; !ok && len(sc.Normalization) > 0 {
+		return errors.New("search normalization can only be none, min-max, z-score, rank-percentile or log")
+	}
+
+	if sc.ResultCacheSize < 0 {
+		return errors.New("search result cache size cannot be negative")
+	}
+
+	if sc.MaxSimilar < 0 {
+		return errors.New("search maximum number of similar users cannot be negative")
+	}
+
 	return nil
 }
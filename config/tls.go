@@ -0,0 +1,77 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// defaultTLSMinVersion is the minimum TLS version used when none is
+// configured.
+const defaultTLSMinVersion = "1.2"
+
+// tlsVersions maps the TLS versions accepted in the configuration file to
+// their crypto/tls identifiers.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSEnabled returns true when the server is configured to serve HTTPS.
+func (sc ServerConfig) TLSEnabled() bool {
+	return len(sc.TLSCertFile) > 0 && len(sc.TLSKeyFile) > 0
+}
+
+// TLSConfig loads the certificates specified in the configuration and
+// creates the TLS configuration of the server. It returns nil when TLS is
+// not enabled.
+func (sc ServerConfig) TLSConfig() (*tls.Config, error) {
+	if !sc.TLSEnabled() {
+		return nil, nil
+	}
+
+	minVersion := sc.TLSMinVersion
+	if len(minVersion) == 0 {
+		minVersion = defaultTLSMinVersion
+	}
+	version, ok := tlsVersions[minVersion]
+	if !ok {
+		return nil, errors.New("server TLS minimum version can only be 1.0, 1.1, 1.2 or 1.3")
+	}
+
+	cert, err := tls.LoadX509KeyPair(sc.TLSCertFile, sc.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("server TLS certificate: %v", err)
+	}
+
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   version,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+
+	if len(sc.TLSClientCAFile) > 0 {
+		bs, err := ioutil.ReadFile(sc.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("server TLS client CA: %v", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bs) {
+			return nil, errors.New("server TLS client CA: no certificate found in " +
+				sc.TLSClientCAFile)
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsCfg, nil
+}
@@ -6,10 +6,12 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/golang/glog"
@@ -17,14 +19,46 @@ import (
 
 	"github.com/DevMine/api-server/cache"
 	"github.com/DevMine/api-server/config"
+	"github.com/DevMine/api-server/metrics"
 	"github.com/DevMine/api-server/srv"
+	"github.com/DevMine/api-server/store"
 )
 
 func fatal(a ...interface{}) {
 	glog.Error(a)
+	glog.Flush()
 	os.Exit(1)
 }
 
+// reloadCache reloads the cache each time SIGHUP is received and, if
+// configured, periodically.
+func reloadCache(cfg config.CacheConfig) {
+	hupc := make(chan os.Signal, 1)
+	signal.Notify(hupc, syscall.SIGHUP)
+
+	var tickc <-chan time.Time
+	if cfg.ReloadInterval > 0 {
+		ticker := time.NewTicker(time.Duration(cfg.ReloadInterval) * time.Second)
+		defer ticker.Stop()
+		tickc = ticker.C
+	}
+
+	for {
+		select {
+		case <-hupc:
+			glog.Info("received SIGHUP, reloading cache...")
+		case <-tickc:
+			glog.Info("reloading cache...")
+		}
+
+		if err := cache.Reload(); err != nil {
+			glog.Error("cache reload failed: ", err)
+			continue
+		}
+		glog.Info("cache reloaded in ", cache.Current().LoadDuration())
+	}
+}
+
 func main() {
 	configPath := flag.String("c", "", "configuration file")
 	flag.Parse()
@@ -50,19 +84,83 @@ func main() {
 	defer db.Close()
 
 	glog.Info("caching data...")
-	tic := time.Now()
 	err = cache.LoadCache(db)
 	if err != nil {
 		fatal(err)
 	}
-	toc := time.Now()
-	glog.Info("done in ", toc.Sub(tic))
+	glog.Info("done in ", cache.Current().LoadDuration())
+
+	go reloadCache(cfg.Cache)
 
-	router := srv.SetupRouter(db, cfg.Server.EnableCors)
-	addr := fmt.Sprintf("%s:%d", cfg.Server.HostName, cfg.Server.Port)
-	glog.Infof("listening on %s...\n", addr)
-	err = http.ListenAndServe(addr, router)
+	st, err := store.NewPostgres(db)
 	if err != nil {
 		fatal(err)
 	}
+
+	if cfg.Metrics.Enabled {
+		if err := metrics.RegisterDB(db, cfg.Database.DBName); err != nil {
+			fatal(err)
+		}
+	}
+
+	router := srv.SetupRouter(st, cfg)
+	server, err := srv.NewServer(cfg.Server, router)
+	if err != nil {
+		fatal(err)
+	}
+	servers := []*http.Server{server}
+	if cfg.Server.HTTPRedirectPort > 0 {
+		servers = append(servers, srv.NewRedirectServer(cfg.Server))
+	}
+	if cfg.Metrics.Enabled && cfg.Metrics.Port > 0 {
+		servers = append(servers, srv.NewMetricsServer(cfg.Metrics))
+	}
+
+	errc := make(chan error, len(servers))
+	for _, server := range servers {
+		go func(server *http.Server) {
+			glog.Infof("listening on %s...\n", server.Addr)
+			errc <- srv.ListenAndServe(server)
+		}(server)
+	}
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case err := <-errc:
+		// a server stopped without being asked to
+		db.Close()
+		fatal(err)
+	case sig := <-sigc:
+		glog.Infof("received %s, shutting down...", sig)
+	}
+	signal.Stop(sigc)
+
+	// Stop accepting new connections and wait for in-flight requests to
+	// complete before closing the database connections pool.
+	ctx := context.Background()
+	if cfg.Server.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		timeout := time.Duration(cfg.Server.ShutdownTimeout) * time.Second
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			glog.Error("graceful shutdown failed: ", err)
+			if err := server.Close(); err != nil {
+				glog.Error(err)
+			}
+		}
+	}
+
+	for range servers {
+		if err := <-errc; err != nil && err != http.ErrServerClosed {
+			glog.Error(err)
+		}
+	}
+
+	glog.Info("server stopped")
 }
@@ -0,0 +1,170 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package metrics collects metrics about the API server and exposes them in
+// the Prometheus text format.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/DevMine/api-server/cache"
+)
+
+// namespace prefixes the names of all metrics.
+const namespace = "devmine"
+
+var (
+	requestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Number of HTTP requests served, by route, method and status code.",
+		},
+		[]string{"route", "method", "code"})
+
+	requestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Time spent serving HTTP requests, by route and method.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"route", "method"})
+
+	panicsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "panics_total",
+			Help:      "Number of panics recovered while serving HTTP requests, by route.",
+		},
+		[]string{"route"})
+
+	rankDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "score",
+			Name:      "rank_duration_seconds",
+			Help:      "Time spent ranking users for search queries.",
+			Buckets:   prometheus.DefBuckets,
+		})
+
+	resultCacheRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "search",
+			Name:      "result_cache_requests_total",
+			Help:      "Number of lookups in the search result cache, by result (hit or miss).",
+		},
+		[]string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		requestsTotal, requestDuration, panicsTotal, rankDuration,
+		resultCacheRequestsTotal, cacheCollector{})
+}
+
+// ObserveRequest records a request served by the given route.
+func ObserveRequest(route, method string, code int, d time.Duration) {
+	requestsTotal.WithLabelValues(route, method, strconv.Itoa(code)).Inc()
+	requestDuration.WithLabelValues(route, method).Observe(d.Seconds())
+}
+
+// ObservePanic records a panic recovered while serving the given route.
+func ObservePanic(route string) {
+	panicsTotal.WithLabelValues(route).Inc()
+}
+
+// ObserveRank records the time spent ranking users for a search query.
+func ObserveRank(d time.Duration) {
+	rankDuration.Observe(d.Seconds())
+}
+
+// ObserveResultCache records a lookup in the search result cache.
+func ObserveResultCache(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	resultCacheRequestsTotal.WithLabelValues(result).Inc()
+}
+
+// RegisterDB registers metrics about the given database connections pool.
+func RegisterDB(db *sql.DB, name string) error {
+	return prometheus.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler returns an HTTP handler serving the metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// cacheCollector collects metrics about the current cache snapshot.
+type cacheCollector struct{}
+
+var (
+	cacheGenerationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cache", "generation"),
+		"Generation number of the cache, incremented on each load.",
+		nil, nil)
+	cacheLoadTimeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cache", "last_load_timestamp_seconds"),
+		"Time at which the cache was last loaded, as a Unix timestamp.",
+		nil, nil)
+	cacheLoadDurationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cache", "last_load_duration_seconds"),
+		"Time it took to last load the cache.",
+		nil, nil)
+	cacheUsersDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cache", "users"),
+		"Number of users, ie rows of the scores matrix, in the cache.",
+		nil, nil)
+	cacheFeaturesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cache", "features"),
+		"Number of features, ie columns of the scores matrix, in the cache.",
+		nil, nil)
+	cacheNonZeroScoresDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "cache", "nonzero_scores"),
+		"Number of non-zero entries of the scores matrix in the cache.",
+		nil, nil)
+)
+
+// Describe implements the prometheus.Collector interface.
+func (cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheGenerationDesc
+	ch <- cacheLoadTimeDesc
+	ch <- cacheLoadDurationDesc
+	ch <- cacheUsersDesc
+	ch <- cacheFeaturesDesc
+	ch <- cacheNonZeroScoresDesc
+}
+
+// Collect implements the prometheus.Collector interface.
+func (cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	s := cache.Latest()
+	if s == nil {
+		return
+	}
+
+	gauge := func(desc *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v)
+	}
+	gauge(cacheGenerationDesc, float64(s.Generation()))
+	gauge(cacheLoadTimeDesc, float64(s.LoadedAt().UnixNano())/1e9)
+	gauge(cacheLoadDurationDesc, s.LoadDuration().Seconds())
+	gauge(cacheUsersDesc, float64(len(s.UsersVector())))
+	gauge(cacheFeaturesDesc, float64(len(s.Features())))
+	gauge(cacheNonZeroScoresDesc, float64(s.NonZeroScores()))
+}
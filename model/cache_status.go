@@ -0,0 +1,20 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package model
+
+import (
+	"time"
+)
+
+// CacheStatus represents the state of the in-memory cache.
+type CacheStatus struct {
+	Generation uint64     `json:"generation"`
+	LoadedAt   *time.Time `json:"loaded_at"`
+
+	// LoadDuration is the time, in seconds, it took to load the cache.
+	LoadDuration float64 `json:"load_duration"`
+
+	Reloading bool `json:"reloading"`
+}
@@ -0,0 +1,28 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package model
+
+// FeatureScore represents the score of a user for a feature, along with the
+// standing of the user among all users and statistics about the scores of all
+// users for the feature.
+type FeatureScore struct {
+	Category      *string `json:"category"`
+	DefaultWeight *int64  `json:"default_weight"`
+
+	Score float64 `json:"score"`
+
+	// Percentile is the percentile rank of the score, between 0 and 1: the
+	// fraction of users with a lower score, counting half of the users with
+	// the same score.
+	Percentile float64 `json:"percentile"`
+
+	// Rank is the position of the user when users are sorted by decreasing
+	// score, starting at 1. Users with the same score share the same rank.
+	Rank int `json:"rank"`
+
+	// Mean and Median are the mean and the median of the scores of all users.
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+}
@@ -0,0 +1,27 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package model
+
+// RankPosition represents the position of a user among the users ranked by a
+// search query, as computed by the Position() function from score package.
+type RankPosition struct {
+	SearchResult
+
+	// Position is the position of the user in the search results, starting
+	// at 1.
+	Position int `json:"position"`
+
+	// Total is the number of ranked users.
+	Total int `json:"total"`
+
+	// Percentile is the share of ranked users ranked lower than the user,
+	// between 0 and 1.
+	Percentile float64 `json:"percentile"`
+
+	// Above and Below are the users ranked just above, respectively just
+	// below, the user, sorted by rank.
+	Above SearchResults `json:"above"`
+	Below SearchResults `json:"below"`
+}
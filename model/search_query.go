@@ -0,0 +1,137 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package model
+
+// Search modes.
+const (
+	// SearchModeRank ranks users.
+	SearchModeRank = "rank"
+
+	// SearchModeSkyline returns users by skyline layer.
+	SearchModeSkyline = "skyline"
+)
+
+// SearchQuery represents a structured search query, as given to the /search
+// route.
+type SearchQuery struct {
+	// Mode is the search mode, SearchModeRank by default.
+	Mode string `json:"mode"`
+
+	// Weights maps feature names to their weights. Features which are not
+	// part of the map are given their default weight whereas features with a
+	// weight of 0 are ignored. Negative weights, which penalize a feature, are
+	// only accepted when Ranking.AllowNegativeWeights is set.
+	Weights map[string]float64 `json:"weights"`
+
+	// Filters restrict the users to rank.
+	Filters SearchFilters `json:"filters"`
+
+	// Page and PerPage paginate the results. The "page" and "per_page"
+	// parameters of the request, when given, take precedence.
+	Page    uint64 `json:"page"`
+	PerPage uint64 `json:"per_page"`
+
+	// Fields lists the fields of the results to return. All fields are
+	// returned when empty.
+	Fields []string `json:"fields"`
+
+	// Explain specifies whether to include, for each result, the
+	// contribution of each feature to the rank.
+	Explain bool `json:"explain"`
+
+	// Ranking holds the options of the ranking.
+	Ranking RankingOptions `json:"ranking"`
+
+	// Skyline holds the options of the skyline mode.
+	Skyline SkylineOptions `json:"skyline"`
+
+	// Diversity holds the options of the diversity re-ranking.
+	Diversity DiversityOptions `json:"diversity"`
+}
+
+// DiversityOptions are the options of the re-ranking of search results which
+// penalizes users too similar to higher ranked ones.
+type DiversityOptions struct {
+	// Lambda, between 0 and 1, is the trade-off between relevance and
+	// diversity: 1 keeps the results sorted by rank whereas 0 only favours
+	// diversity. The results are only re-ranked when Lambda is given.
+	Lambda *float64 `json:"lambda"`
+
+	// By is the criterion used to compare users: "scores" (default) or
+	// "affiliation".
+	By string `json:"by"`
+}
+
+// SkylineOptions are the options of the skyline search mode.
+type SkylineOptions struct {
+	// Features are the names of the features the skyline is computed over.
+	// Higher scores are better, unless the name is prefixed by '-'.
+	Features []string `json:"features"`
+
+	// Layer, when greater than 0, restricts the results to the given
+	// skyline layer.
+	Layer int `json:"layer"`
+}
+
+// SearchFilters restrict the users considered by a search query. A user
+// must satisfy all the filters to be ranked.
+type SearchFilters struct {
+	// Scores maps feature names to the range in which the score of a user
+	// must be.
+	Scores map[string]ScoreRange `json:"scores"`
+
+	// Location and Company must be part, case insensitively, of the location
+	// and the company of the GitHub profile of the user.
+	Location string `json:"location"`
+	Company  string `json:"company"`
+
+	// Hireable must match the hireable flag of the GitHub profile of the
+	// user.
+	Hireable *bool `json:"hireable"`
+
+	// Organizations lists GitHub organizations the user must be a member of
+	// at least one of.
+	Organizations []string `json:"organizations"`
+
+	// Followers is the range in which the number of followers of the user
+	// must be.
+	Followers *ScoreRange `json:"followers"`
+
+	// Languages lists languages at least one of which must be the primary
+	// language of a repository associated to the user.
+	Languages []string `json:"languages"`
+}
+
+// ScoreRange is a range of values. A nil bound means no bound.
+type ScoreRange struct {
+	Min *float64 `json:"min"`
+	Max *float64 `json:"max"`
+}
+
+// Contains reports whether score is within the range.
+func (sr ScoreRange) Contains(score float64) bool {
+	return (sr.Min == nil || score >= *sr.Min) && (sr.Max == nil || score <= *sr.Max)
+}
+
+// RankingOptions alter the way users are ranked by a search query.
+type RankingOptions struct {
+	// MinRank excludes the users ranked lower than MinRank from the results.
+	MinRank *float64 `json:"min_rank"`
+
+	// AllowNegativeWeights specifies whether negative weights are accepted.
+	AllowNegativeWeights bool `json:"allow_negative_weights"`
+
+	// Ranker is the name of the ranking algorithm: weighted-sum (the
+	// default), lp-distance, topsis or lexicographic.
+	Ranker string `json:"ranker"`
+
+	// P is the order of the norm used by the lp-distance ranker. Defaults
+	// to 2.
+	P float64 `json:"p"`
+
+	// Normalization is the normalization mode of the scores: none, min-max,
+	// z-score, rank-percentile or log.
+	Normalization string `json:"normalization"`
+}
@@ -9,6 +9,33 @@ package model
 type SearchResult struct {
 	User
 	Rank float64 `json:"rank"`
+
+	// Layer is the skyline layer of the user. It is only set by skyline
+	// queries.
+	Layer int `json:"layer,omitempty"`
+
+	// Explanation details how the rank is computed. It is only set when
+	// requested.
+	Explanation []FeatureContribution `json:"explanation,omitempty"`
+}
+
+// FeatureContribution is the contribution of a feature to the rank of a user.
+type FeatureContribution struct {
+	Feature string  `json:"feature"`
+	Score   float64 `json:"score"`
+
+	// NormalizedScore is the score once normalized. It is only set when the
+	// scores are normalized.
+	NormalizedScore *float64 `json:"normalized_score,omitempty"`
+
+	Weight float64 `json:"weight"`
+
+	// DefaultWeight is true when the weight is the default weight of the
+	// feature rather than a weight given in the query.
+	DefaultWeight bool `json:"default_weight"`
+
+	// Contribution is the (normalized) score multiplied by the weight.
+	Contribution float64 `json:"contribution"`
 }
 
 // SearchResults is used to store search results and is sortable
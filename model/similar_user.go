@@ -0,0 +1,23 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package model
+
+// SimilarUser represents a user similar to another one, as computed by the
+// Similar() function from score package.
+type SimilarUser struct {
+	User
+
+	// Distance is the distance between the scores of both users. The lower,
+	// the more similar.
+	Distance float64 `json:"distance"`
+
+	// Similarity is the similarity between the scores of both users, between
+	// 0 and 1 (or -1 and 1 for the cosine similarity). The higher, the more
+	// similar.
+	Similarity float64 `json:"similarity"`
+}
+
+// SimilarUsers is used to store similar users.
+type SimilarUsers []SimilarUser
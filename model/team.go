@@ -0,0 +1,57 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package model
+
+// TeamQuery represents a query to the /teams route, looking for small teams
+// of users which together meet minimum scores on several features.
+type TeamQuery struct {
+	// Requirements maps the names of the required features to the minimum
+	// score a member must have to cover the feature.
+	Requirements map[string]float64 `json:"requirements"`
+
+	// MaxSize is the maximum number of members of a team. When 0, it defaults
+	// to the number of requirements.
+	MaxSize int `json:"max_size"`
+
+	// Count is the number of candidate teams to return. When 0, a default
+	// number of teams is returned.
+	Count int `json:"count"`
+
+	// Filters restrict the users which can be part of a team.
+	Filters SearchFilters `json:"filters"`
+}
+
+// Team represents a candidate team, as computed by the Teams() function from
+// score package.
+type Team struct {
+	Members []TeamMember `json:"members"`
+
+	// Missing lists the required features that no member covers.
+	Missing []string `json:"missing"`
+
+	// Rank is the strength of the team: the sum, over the covered features,
+	// of the score of the member covering the feature divided by the highest
+	// score of the feature.
+	Rank float64 `json:"rank"`
+}
+
+// TeamMember is a member of a team.
+type TeamMember struct {
+	User
+
+	// Contributions are the required features covered by the member. Each
+	// feature is covered by a single member.
+	Contributions []TeamContribution `json:"contributions"`
+}
+
+// TeamContribution is a required feature covered by a member of a team.
+type TeamContribution struct {
+	Feature string  `json:"feature"`
+	Score   float64 `json:"score"`
+	Min     float64 `json:"min"`
+}
+
+// Teams is used to store teams.
+type Teams []Team
@@ -0,0 +1,12 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package model
+
+// UserScore represents the score of a user for a given feature.
+type UserScore struct {
+	ID       *int64   `json:"id"`
+	Username *string  `json:"username"`
+	Score    *float64 `json:"score"`
+}
@@ -0,0 +1,137 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package ratelimit implements token bucket rate limiting of clients.
+//
+// Each client, identified by a key, has a bucket holding at most Burst
+// tokens. Every request takes a token from the bucket and buckets are
+// refilled at a constant rate. Requests are rejected while the bucket of the
+// client is empty.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is the interval at which buckets of idle clients are
+// removed.
+const sweepInterval = time.Minute
+
+// Limit defines the rate at which clients are allowed to make requests.
+type Limit struct {
+	// Rate is the number of tokens added to a bucket per second.
+	Rate float64
+
+	// Burst is the capacity of a bucket, ie the maximum number of requests
+	// a client can make at once.
+	Burst int
+}
+
+// Result is the outcome of a call to Allow.
+type Result struct {
+	// Allowed is true when the request is allowed.
+	Allowed bool
+
+	// Limit is the capacity of the bucket.
+	Limit int
+
+	// Remaining is the number of requests the client can still make at
+	// once.
+	Remaining int
+
+	// Reset is the time at which the bucket of the client is full again.
+	Reset time.Time
+
+	// RetryAfter is the time to wait for the next request to be allowed. It
+	// is only set when the request is not allowed.
+	RetryAfter time.Duration
+}
+
+// bucket is the token bucket of a client.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter limits the rate of requests of clients. It is safe for concurrent
+// use.
+type Limiter struct {
+	limit Limit
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+
+	// now returns the current time.
+	now func() time.Time
+}
+
+// New creates a Limiter applying the given limit to each client.
+func New(limit Limit) *Limiter {
+	return &Limiter{
+		limit:     limit,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Allow takes a token from the bucket of the client identified by key and
+// reports whether the request is allowed.
+func (l *Limiter) Allow(key string) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	capacity := float64(l.limit.Burst)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	} else {
+		b.tokens = l.refill(b, now)
+		b.last = now
+	}
+
+	res := Result{Limit: l.limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = l.duration(1 - b.tokens)
+	}
+	res.Remaining = int(math.Floor(b.tokens))
+	res.Reset = now.Add(l.duration(capacity - b.tokens))
+
+	return res
+}
+
+// refill returns the number of tokens in bucket b at time now.
+func (l *Limiter) refill(b *bucket, now time.Time) float64 {
+	tokens := b.tokens + now.Sub(b.last).Seconds()*l.limit.Rate
+	return math.Min(tokens, float64(l.limit.Burst))
+}
+
+// duration returns the time it takes to add the given number of tokens to a
+// bucket.
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens / l.limit.Rate * float64(time.Second)))
+}
+
+// sweep removes the buckets which are full, since they hold the same number
+// of tokens as a new bucket. It must be called with l.mu held.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,126 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// clock is a fake clock which only moves forward when told so.
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time                  { return c.t }
+func (c *clock) advance(d time.Duration)         { c.t = c.t.Add(d) }
+func (c *clock) after(d time.Duration) time.Time { return c.t.Add(d) }
+
+// newTestLimiter creates a limiter driven by a fake clock.
+func newTestLimiter(limit Limit) (*Limiter, *clock) {
+	c := &clock{t: time.Unix(1420815587, 0)}
+	l := New(limit)
+	l.now = c.now
+	l.lastSweep = c.t
+	return l, c
+}
+
+func checkResult(t *testing.T, step string, got, want Result) {
+	if got.Allowed != want.Allowed || got.Limit != want.Limit ||
+		got.Remaining != want.Remaining || !got.Reset.Equal(want.Reset) ||
+		got.RetryAfter != want.RetryAfter {
+		t.Errorf("%s: got %+v, want %+v", step, got, want)
+	}
+}
+
+func TestAllowBurst(t *testing.T) {
+	l, c := newTestLimiter(Limit{Rate: 1, Burst: 3})
+	start := c.t
+
+	for i := 0; i < 3; i++ {
+		checkResult(t, "burst", l.Allow("a"), Result{
+			Allowed:   true,
+			Limit:     3,
+			Remaining: 2 - i,
+			Reset:     start.Add(time.Duration(i+1) * time.Second),
+		})
+	}
+
+	checkResult(t, "empty bucket", l.Allow("a"), Result{
+		Limit:      3,
+		Reset:      start.Add(3 * time.Second),
+		RetryAfter: time.Second,
+	})
+
+	// Other clients have their own bucket.
+	checkResult(t, "other client", l.Allow("b"), Result{
+		Allowed:   true,
+		Limit:     3,
+		Remaining: 2,
+		Reset:     start.Add(time.Second),
+	})
+}
+
+func TestAllowRefill(t *testing.T) {
+	l, c := newTestLimiter(Limit{Rate: 2, Burst: 2})
+
+	l.Allow("a")
+	l.Allow("a")
+
+	// Half a token was added: the client has to wait for 250ms more.
+	c.advance(250 * time.Millisecond)
+	checkResult(t, "half a token", l.Allow("a"), Result{
+		Limit:      2,
+		Reset:      c.after(750 * time.Millisecond),
+		RetryAfter: 250 * time.Millisecond,
+	})
+
+	c.advance(250 * time.Millisecond)
+	checkResult(t, "one token", l.Allow("a"), Result{
+		Allowed: true,
+		Limit:   2,
+		Reset:   c.after(time.Second),
+	})
+
+	// Buckets do not hold more than Burst tokens.
+	c.advance(time.Hour)
+	checkResult(t, "full bucket", l.Allow("a"), Result{
+		Allowed:   true,
+		Limit:     2,
+		Remaining: 1,
+		Reset:     c.after(500 * time.Millisecond),
+	})
+}
+
+func TestSweep(t *testing.T) {
+	l, c := newTestLimiter(Limit{Rate: 0.02, Burst: 2})
+
+	l.Allow("a")
+	l.Allow("b")
+	l.Allow("b")
+
+	// After a minute, the bucket of a is full again whereas the one of b
+	// still lacks 0.8 token.
+	c.advance(sweepInterval - time.Second)
+	l.Allow("c")
+	if len(l.buckets) != 3 {
+		t.Fatalf("got %d buckets before the sweep interval, want 3", len(l.buckets))
+	}
+
+	c.advance(time.Second)
+	l.Allow("c")
+	if _, ok := l.buckets["a"]; ok {
+		t.Error("full bucket of a was not swept")
+	}
+	if _, ok := l.buckets["b"]; !ok {
+		t.Error("bucket of b was swept before being full")
+	}
+	if _, ok := l.buckets["c"]; !ok {
+		t.Error("bucket of c was swept before being full")
+	}
+	if !l.lastSweep.Equal(c.t) {
+		t.Errorf("got last sweep at %v, want %v", l.lastSweep, c.t)
+	}
+}
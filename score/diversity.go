@@ -0,0 +1,174 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package score
+
+import (
+	"github.com/DevMine/api-server/cache"
+	"github.com/DevMine/api-server/model"
+)
+
+// Criteria used to compare users when diversifying search results.
+const (
+	// DiversityScores compares the score vectors of users, with the cosine
+	// similarity over the features contributing to the rank.
+	DiversityScores = "scores"
+
+	// DiversityAffiliation considers users sharing an organization or a
+	// company as identical, and other users as different.
+	DiversityAffiliation = "affiliation"
+)
+
+// IsDiversity reports whether by is a valid diversity criterion.
+func IsDiversity(by string) bool {
+	return by == DiversityScores || by == DiversityAffiliation
+}
+
+// Diversify re-orders search results using maximal marginal relevance
+// (MMR): the results are selected one by one, each time picking the result
+// maximizing
+//
+//	lambda * relevance - (1 - lambda) * similarity
+//
+// where relevance is the rank of the result scaled to [0, 1] and similarity
+// is the highest similarity between the result and the ones already
+// selected. Only the first n results are selected this way; the others keep
+// their relative order. The results must have been computed from the same
+// snapshot for query q, whose diversity options are used.
+func Diversify(snap *cache.Snapshot, q *model.SearchQuery, results model.SearchResults, n int) error {
+	if q.Diversity.Lambda == nil || len(results) < 2 {
+		return nil
+	}
+	lambda := *q.Diversity.Lambda
+	if n > len(results) {
+		n = len(results)
+	}
+
+	rows := make([]int, len(results))
+	for i := range results {
+		row, ok := snap.UserRow(*results[i].ID)
+		if !ok {
+			row = -1
+		}
+		rows[i] = row
+	}
+
+	similarity, err := similarityFunc(snap, q)
+	if err != nil {
+		return err
+	}
+
+	// scale ranks to [0, 1] so that they are comparable to similarities
+	minRank, maxRank := results[0].Rank, results[0].Rank
+	for _, res := range results {
+		if res.Rank < minRank {
+			minRank = res.Rank
+		}
+		if res.Rank > maxRank {
+			maxRank = res.Rank
+		}
+	}
+	relevance := make([]float64, len(results))
+	for i, res := range results {
+		if maxRank > minRank {
+			relevance[i] = (res.Rank - minRank) / (maxRank - minRank)
+		} else {
+			relevance[i] = 1
+		}
+	}
+
+	selected := make([]bool, len(results))
+	maxSim := make([]float64, len(results))
+	order := make([]int, 0, len(results))
+	for len(order) < n {
+		best, bestScore := -1, 0.0
+		for i := range results {
+			if selected[i] {
+				continue
+			}
+			score := lambda*relevance[i] - (1-lambda)*maxSim[i]
+			if best < 0 || score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+
+		selected[best] = true
+		order = append(order, best)
+
+		for i := range results {
+			if selected[i] || rows[i] < 0 || rows[best] < 0 {
+				continue
+			}
+			if sim := similarity(rows[best], rows[i]); sim > maxSim[i] {
+				maxSim[i] = sim
+			}
+		}
+	}
+	for i := range results {
+		if !selected[i] {
+			order = append(order, i)
+		}
+	}
+
+	reordered := make(model.SearchResults, len(results))
+	for k, i := range order {
+		reordered[k] = results[i]
+	}
+	copy(results, reordered)
+
+	return nil
+}
+
+// similarityFunc returns the function computing the similarity, between 0 and
+// 1, of the users at two rows of the scores matrix, according to the
+// diversity criterion of query q.
+func similarityFunc(snap *cache.Snapshot, q *model.SearchQuery) (func(a, b int) float64, error) {
+	if q.Diversity.By == DiversityAffiliation {
+		return func(a, b int) float64 {
+			if sharesAffiliation(snap.Profile(a), snap.Profile(b)) {
+				return 1
+			}
+			return 0
+		}, nil
+	}
+
+	m, err := normalizedMatrix(snap, q.Ranking.Normalization)
+	if err != nil {
+		return nil, err
+	}
+
+	weights, _ := effectiveWeights(snap.Features(), q.Weights)
+	var cols []int
+	for j, w := range weights {
+		if w != 0 {
+			cols = append(cols, j)
+		}
+	}
+
+	cosine := metrics[MetricCosine]
+	return func(a, b int) float64 {
+		_, sim := cosine(m, a, b, cols)
+		if sim < 0 {
+			// opposite score vectors are as different as can be
+			return 0
+		}
+		return sim
+	}, nil
+}
+
+// sharesAffiliation reports whether the given profiles have a company or an
+// organization in common.
+func sharesAffiliation(a, b *cache.Profile) bool {
+	if len(a.Company) > 0 && a.Company == b.Company {
+		return true
+	}
+	for _, orgA := range a.Organizations {
+		for _, orgB := range b.Organizations {
+			if orgA == orgB {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package score
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DevMine/api-server/cache"
+	"github.com/DevMine/api-server/model"
+)
+
+// diversityScores are the scores of 4 users on features a and b. Their ranks
+// are 10, 9, 7 and 6, hence a relevance of 1, 0.75, 0.25 and 0. The cosine
+// similarity of u0 and u1 is 1, the one of u3 with any other user is
+// sqrt(2) / 2 and the one of u2 with u0 or u1 is 0.
+var diversityScores = [][]float64{
+	{10, 0},
+	{9, 0},
+	{0, 7},
+	{3, 3},
+}
+
+// diversityProfiles are the profiles of the users having diversityScores: u0
+// and u1 work for the same company whereas u2 and u3 have no affiliation in
+// common with anyone.
+var diversityProfiles = []cache.Profile{
+	{Company: "acme"},
+	{Company: "acme", Organizations: []string{"golang"}},
+	{Organizations: []string{"rust-lang"}},
+	{},
+}
+
+func TestDiversify(t *testing.T) {
+	snap := loadSnapshot(t, diversityScores, diversityProfiles)
+
+	lambda := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name      string
+		diversity model.DiversityOptions
+		n         int
+		want      []string
+	}{
+		{
+			name: "no diversification",
+			n:    4,
+			want: []string{"u0", "u1", "u2", "u3"},
+		},
+		{
+			name:      "relevance only",
+			diversity: model.DiversityOptions{Lambda: lambda(1)},
+			n:         4,
+			want:      []string{"u0", "u1", "u2", "u3"},
+		},
+		{
+			// after u0, u2 scores 0.125, u1 -0.125 and u3 -0.354, then u1
+			// is still preferred to u3
+			name:      "balanced",
+			diversity: model.DiversityOptions{Lambda: lambda(0.5)},
+			n:         4,
+			want:      []string{"u0", "u2", "u1", "u3"},
+		},
+		{
+			// after u0 and u2, u3 is less similar to them than u1
+			name:      "similarity only",
+			diversity: model.DiversityOptions{Lambda: lambda(0)},
+			n:         4,
+			want:      []string{"u0", "u2", "u3", "u1"},
+		},
+		{
+			name:      "first results only",
+			diversity: model.DiversityOptions{Lambda: lambda(0)},
+			n:         2,
+			want:      []string{"u0", "u2", "u1", "u3"},
+		},
+		{
+			// after u0, u2 scores 0.125, u3 0 and u1 -0.125, then u3
+			// scores 0 and u1 -0.125
+			name:      "affiliation",
+			diversity: model.DiversityOptions{Lambda: lambda(0.5), By: DiversityAffiliation},
+			n:         4,
+			want:      []string{"u0", "u2", "u3", "u1"},
+		},
+	}
+
+	for _, tt := range tests {
+		q := &model.SearchQuery{Diversity: tt.diversity}
+
+		results, err := Rank(snap, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Diversify(snap, q, results, tt.n); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		if got := usernames(results); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
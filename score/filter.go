@@ -0,0 +1,137 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package score
+
+import (
+	"strings"
+
+	"github.com/DevMine/api-server/cache"
+	"github.com/DevMine/api-server/model"
+)
+
+// rowFilter reports whether the user at the given row of the scores matrix
+// satisfies a filter.
+type rowFilter func(row int) bool
+
+// selectRows returns the rows of the scores matrix, in increasing order, of
+// the users satisfying all the filters. It returns nil when there is no
+// filter at all.
+// Filters on organizations and languages use the indexes of the snapshot to
+// select candidate users, which are then checked against the other filters.
+func selectRows(snap *cache.Snapshot, filters model.SearchFilters) []int {
+	var candidates []int
+	indexed := false
+
+	intersect := func(rows []int) {
+		if !indexed {
+			candidates, indexed = rows, true
+			return
+		}
+		candidates = intersectRows(candidates, rows)
+	}
+	if len(filters.Organizations) > 0 {
+		intersect(snap.RowsByOrganization(filters.Organizations...))
+	}
+	if len(filters.Languages) > 0 {
+		intersect(snap.RowsByLanguage(filters.Languages...))
+	}
+
+	checks := rowFilters(snap, filters)
+	if !indexed && len(checks) == 0 {
+		return nil
+	}
+
+	if !indexed {
+		n, _ := snap.ScoresMatrix().Dims()
+		candidates = make([]int, n)
+		for i := range candidates {
+			candidates[i] = i
+		}
+	}
+
+	rows := make([]int, 0, len(candidates))
+	for _, row := range candidates {
+		if satisfies(row, checks) {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// rowFilters returns the filters which are checked user by user.
+func rowFilters(snap *cache.Snapshot, filters model.SearchFilters) []rowFilter {
+	var checks []rowFilter
+
+	sm := snap.ScoresMatrix()
+	for feat, sr := range filters.Scores {
+		col, ok := snap.FeatureColumn(feat)
+		if !ok {
+			continue
+		}
+		sr := sr
+		checks = append(checks, func(row int) bool {
+			return sr.Contains(sm.At(row, col))
+		})
+	}
+
+	if location := strings.ToLower(filters.Location); len(location) > 0 {
+		checks = append(checks, func(row int) bool {
+			return strings.Contains(snap.Profile(row).Location, location)
+		})
+	}
+
+	if company := strings.ToLower(filters.Company); len(company) > 0 {
+		checks = append(checks, func(row int) bool {
+			return strings.Contains(snap.Profile(row).Company, company)
+		})
+	}
+
+	if filters.Hireable != nil {
+		hireable := *filters.Hireable
+		checks = append(checks, func(row int) bool {
+			h := snap.Profile(row).Hireable
+			return h != nil && *h == hireable
+		})
+	}
+
+	if filters.Followers != nil {
+		fr := *filters.Followers
+		checks = append(checks, func(row int) bool {
+			f := snap.Profile(row).Followers
+			return f != nil && fr.Contains(float64(*f))
+		})
+	}
+
+	return checks
+}
+
+// satisfies reports whether the user at the given row satisfies all checks.
+func satisfies(row int, checks []rowFilter) bool {
+	for _, check := range checks {
+		if !check(row) {
+			return false
+		}
+	}
+	return true
+}
+
+// intersectRows returns the rows which are part of both a and b, which must
+// be sorted in increasing order.
+func intersectRows(a, b []int) []int {
+	var rows []int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			rows = append(rows, a[i])
+			i++
+			j++
+		}
+	}
+	return rows
+}
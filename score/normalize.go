@@ -0,0 +1,96 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package score
+
+import (
+	"math"
+
+	mx "code.google.com/p/biogo.matrix"
+
+	"github.com/DevMine/api-server/cache"
+)
+
+// Normalization modes of the scores, which bring the scores of all features
+// to comparable scales before weighting them.
+const (
+	// NormNone uses the raw scores.
+	NormNone = "none"
+
+	// NormMinMax scales the scores of each feature to [0, 1].
+	NormMinMax = "min-max"
+
+	// NormZScore centers the scores of each feature on their mean and
+	// divides them by their standard deviation.
+	NormZScore = "z-score"
+
+	// NormPercentile replaces the scores by their percentile rank among the
+	// scores of the feature, in [0, 1].
+	NormPercentile = "rank-percentile"
+
+	// NormLog compresses the scores with a logarithm, preserving their sign.
+	NormLog = "log"
+)
+
+// normalizers maps the normalization modes to the function normalizing a
+// score given the statistics of its column.
+var normalizers = map[string]func(v float64, cs cache.ColumnStats) float64{
+	NormNone: nil,
+	NormMinMax: func(v float64, cs cache.ColumnStats) float64 {
+		if cs.Max == cs.Min {
+			return 0
+		}
+		return (v - cs.Min) / (cs.Max - cs.Min)
+	},
+	NormZScore: func(v float64, cs cache.ColumnStats) float64 {
+		if cs.StdDev == 0 {
+			return 0
+		}
+		return (v - cs.Mean) / cs.StdDev
+	},
+	NormPercentile: func(v float64, cs cache.ColumnStats) float64 {
+		return cs.Percentile(v)
+	},
+	NormLog: func(v float64, _ cache.ColumnStats) float64 {
+		if v < 0 {
+			return -math.Log1p(-v)
+		}
+		return math.Log1p(v)
+	},
+}
+
+// IsNormalization reports whether mode is a valid normalization mode.
+func IsNormalization(mode string) bool {
+	_, ok := normalizers[mode]
+	return ok
+}
+
+// normalizedMatrix returns the scores matrix of the snapshot normalized with
+// the given mode. Normalized matrices are computed once per snapshot and mode.
+func normalizedMatrix(snap *cache.Snapshot, mode string) (mx.Matrix, error) {
+	normalize := normalizers[mode]
+	if normalize == nil {
+		return snap.ScoresMatrix(), nil
+	}
+
+	m, err := snap.Derived("normalized:"+mode, func() (interface{}, error) {
+		sm := snap.ScoresMatrix()
+		stats := snap.ColumnStats()
+		rows, cols := sm.Dims()
+
+		values := make([][]float64, rows)
+		for i := range values {
+			values[i] = make([]float64, cols)
+			for j := range values[i] {
+				values[i][j] = normalize(sm.At(i, j), stats[j])
+			}
+		}
+
+		return mx.NewDense(values)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m.(mx.Matrix), nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package score
+
+import (
+	"sort"
+
+	"github.com/DevMine/api-server/cache"
+	"github.com/DevMine/api-server/model"
+)
+
+// Position returns the position of the user at the given row of the scores
+// matrix among all the users ranked by query q, along with the given number
+// of users ranked just above and just below. Unlike the search results, the
+// ranked users are not truncated. Users are sorted as by Rank.
+// It returns nil if the user is not ranked by the query, because of its
+// filters or minimum rank.
+func Position(snap *cache.Snapshot, q *model.SearchQuery, row, neighbors int) (*model.RankPosition, error) {
+	ranked, err := rankRows(snap, q)
+	if err != nil {
+		return nil, err
+	}
+
+	// ranked rows are in increasing order, which sorting keeps for equal
+	// ranks
+	sort.SliceStable(ranked, func(a, b int) bool {
+		return ranked[a].rank > ranked[b].rank
+	})
+
+	idx := -1
+	for k, rr := range ranked {
+		if rr.row == row {
+			idx = k
+			break
+		}
+	}
+	if idx < 0 {
+		return nil, nil
+	}
+
+	uv := snap.UsersVector()
+	result := func(rr rankedRow) model.SearchResult {
+		return model.SearchResult{User: uv[rr.row], Rank: rr.rank}
+	}
+
+	pos := &model.RankPosition{
+		SearchResult: result(ranked[idx]),
+		Position:     idx + 1,
+		Total:        len(ranked),
+		Above:        model.SearchResults{},
+		Below:        model.SearchResults{},
+	}
+
+	var lower int
+	for _, rr := range ranked[idx+1:] {
+		if rr.rank < ranked[idx].rank {
+			lower++
+		}
+	}
+	pos.Percentile = float64(lower) / float64(len(ranked))
+
+	for k := idx - neighbors; k < idx; k++ {
+		if k >= 0 {
+			pos.Above = append(pos.Above, result(ranked[k]))
+		}
+	}
+	for k := idx + 1; k <= idx+neighbors && k < len(ranked); k++ {
+		pos.Below = append(pos.Below, result(ranked[k]))
+	}
+
+	return pos, nil
+}
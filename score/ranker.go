@@ -0,0 +1,95 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package score
+
+import (
+	"sort"
+	"sync"
+
+	mx "code.google.com/p/biogo.matrix"
+
+	"github.com/DevMine/api-server/model"
+)
+
+// DefaultRanker is the name of the ranker used by queries which do not
+// specify any.
+const DefaultRanker = "weighted-sum"
+
+// Ranker is the interface implemented by ranking algorithms.
+type Ranker interface {
+	// Rank computes the rank of the users at the given rows of the scores
+	// matrix m, or of all users when rows is nil, given the weight of each
+	// feature (column). The higher the rank, the better. The returned slice
+	// holds the rank of each user, in the order of rows.
+	Rank(m mx.Matrix, rows []int, weights []float64, opts model.RankingOptions) ([]float64, error)
+}
+
+var (
+	rankersMu sync.RWMutex
+	rankers   = make(map[string]Ranker)
+)
+
+// Register makes a ranker available under the given name. If Register is
+// called twice with the same name or if ranker is nil, it panics.
+func Register(name string, ranker Ranker) {
+	rankersMu.Lock()
+	defer rankersMu.Unlock()
+
+	if ranker == nil {
+		panic("score: Register ranker is nil")
+	}
+	if _, dup := rankers[name]; dup {
+		panic("score: Register called twice for ranker " + name)
+	}
+	rankers[name] = ranker
+}
+
+// Rankers returns a sorted list of the names of the registered rankers.
+func Rankers() []string {
+	rankersMu.RLock()
+	defer rankersMu.RUnlock()
+
+	names := make([]string, 0, len(rankers))
+	for name := range rankers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsRanker reports whether a ranker is registered under the given name. The
+// empty name designates the default ranker.
+func IsRanker(name string) bool {
+	_, ok := lookupRanker(name)
+	return ok
+}
+
+// lookupRanker returns the ranker registered under the given name, or the
+// default ranker if name is empty.
+func lookupRanker(name string) (Ranker, bool) {
+	if len(name) == 0 {
+		name = DefaultRanker
+	}
+
+	rankersMu.RLock()
+	defer rankersMu.RUnlock()
+
+	ranker, ok := rankers[name]
+	return ranker, ok
+}
+
+// allRows returns the given rows or, if nil, all the rows of matrix m.
+func allRows(m mx.Matrix, rows []int) []int {
+	if rows != nil {
+		return rows
+	}
+
+	n, _ := m.Dims()
+	rows = make([]int, n)
+	for i := range rows {
+		rows[i] = i
+	}
+	return rows
+}
@@ -0,0 +1,220 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package score
+
+import (
+	"math"
+	"sort"
+
+	mx "code.google.com/p/biogo.matrix"
+
+	"github.com/DevMine/api-server/model"
+)
+
+func init() {
+	Register(DefaultRanker, weightedSum{})
+	Register("lp-distance", lpDistance{})
+	Register("topsis", topsis{})
+	Register("lexicographic", lexicographic{})
+}
+
+// weightedSum ranks users by the sum of their scores multiplied by the
+// weights of the features.
+type weightedSum struct{}
+
+// Rank implements the Ranker interface.
+func (weightedSum) Rank(m mx.Matrix, rows []int, weights []float64, _ model.RankingOptions) ([]float64, error) {
+	if rows != nil {
+		ranks := make([]float64, len(rows))
+		for k, i := range rows {
+			ranks[k] = dotRow(m, i, weights)
+		}
+		return ranks, nil
+	}
+
+	// rank all users at once
+	w, err := constructWeightVector(weights)
+	if err != nil {
+		return nil, err
+	}
+	rm := computeRanks(m, w)
+
+	n, _ := rm.Dims()
+	ranks := make([]float64, n)
+	for i := range ranks {
+		ranks[i] = rm.At(i, 0)
+	}
+	return ranks, nil
+}
+
+// defaultP is the default order of the norm used by the lpDistance ranker.
+const defaultP = 2
+
+// idealPoint returns, for each column of matrix m, the best score among the
+// given rows: the highest one for columns with a positive weight, the lowest
+// one for columns with a negative weight. The worst scores are returned as
+// well.
+func idealPoint(m mx.Matrix, rows []int, weights []float64) (best, worst []float64) {
+	best = make([]float64, len(weights))
+	worst = make([]float64, len(weights))
+	for j, w := range weights {
+		if len(rows) == 0 {
+			continue
+		}
+		min, max := m.At(rows[0], j), m.At(rows[0], j)
+		for _, i := range rows[1:] {
+			v := m.At(i, j)
+			min, max = math.Min(min, v), math.Max(max, v)
+		}
+		if w < 0 {
+			best[j], worst[j] = min, max
+		} else {
+			best[j], worst[j] = max, min
+		}
+	}
+	return best, worst
+}
+
+// lpDistance ranks users by the weighted Lp-norm distance between their
+// scores and the ideal point, made of the best score for each feature. The
+// rank is 1 / (1 + distance), so that the closest users rank the highest.
+// The order of the norm is given by the P ranking option.
+type lpDistance struct{}
+
+// Rank implements the Ranker interface.
+func (lpDistance) Rank(m mx.Matrix, rows []int, weights []float64, opts model.RankingOptions) ([]float64, error) {
+	p := opts.P
+	if p == 0 {
+		p = defaultP
+	}
+
+	rows = allRows(m, rows)
+	ideal, _ := idealPoint(m, rows, weights)
+
+	ranks := make([]float64, len(rows))
+	for k, i := range rows {
+		var sum float64
+		for j, w := range weights {
+			if w != 0 {
+				sum += math.Abs(w) * math.Pow(math.Abs(ideal[j]-m.At(i, j)), p)
+			}
+		}
+		ranks[k] = 1 / (1 + math.Pow(sum, 1/p))
+	}
+	return ranks, nil
+}
+
+// topsis ranks users with the Technique for Order of Preference by
+// Similarity to Ideal Solution: scores are normalized by the Euclidean norm of
+// their column and weighted, then users are ranked by their relative
+// closeness to the ideal solution, between 0 and 1. Features with a negative
+// weight are criteria to minimize.
+type topsis struct{}
+
+// Rank implements the Ranker interface.
+func (topsis) Rank(m mx.Matrix, rows []int, weights []float64, _ model.RankingOptions) ([]float64, error) {
+	rows = allRows(m, rows)
+
+	norms := make([]float64, len(weights))
+	for j := range weights {
+		for _, i := range rows {
+			norms[j] += m.At(i, j) * m.At(i, j)
+		}
+		norms[j] = math.Sqrt(norms[j])
+	}
+
+	// weighted normalized value of the score at row i, column j
+	value := func(i, j int) float64 {
+		if norms[j] == 0 {
+			return 0
+		}
+		return math.Abs(weights[j]) * m.At(i, j) / norms[j]
+	}
+
+	best := make([]float64, len(weights))
+	worst := make([]float64, len(weights))
+	for j, w := range weights {
+		for k, i := range rows {
+			v := value(i, j)
+			if k == 0 {
+				best[j], worst[j] = v, v
+				continue
+			}
+			if (w >= 0 && v > best[j]) || (w < 0 && v < best[j]) {
+				best[j] = v
+			}
+			if (w >= 0 && v < worst[j]) || (w < 0 && v > worst[j]) {
+				worst[j] = v
+			}
+		}
+	}
+
+	ranks := make([]float64, len(rows))
+	for k, i := range rows {
+		var dBest, dWorst float64
+		for j := range weights {
+			v := value(i, j)
+			dBest += (v - best[j]) * (v - best[j])
+			dWorst += (v - worst[j]) * (v - worst[j])
+		}
+		dBest, dWorst = math.Sqrt(dBest), math.Sqrt(dWorst)
+
+		if dBest+dWorst > 0 {
+			ranks[k] = dWorst / (dBest + dWorst)
+		}
+	}
+	return ranks, nil
+}
+
+// lexicographic ranks users by comparing their scores feature by feature, by
+// decreasing absolute weight: a feature is only considered when users are
+// tied on all features with a higher absolute weight. Features with a
+// negative weight are compared in reverse order and features with a weight of
+// 0 are ignored. The rank is the fraction of users ranked strictly lower.
+type lexicographic struct{}
+
+// Rank implements the Ranker interface.
+func (lexicographic) Rank(m mx.Matrix, rows []int, weights []float64, _ model.RankingOptions) ([]float64, error) {
+	rows = allRows(m, rows)
+
+	var priority []int
+	for j, w := range weights {
+		if w != 0 {
+			priority = append(priority, j)
+		}
+	}
+	sort.SliceStable(priority, func(a, b int) bool {
+		return math.Abs(weights[priority[a]]) > math.Abs(weights[priority[b]])
+	})
+
+	// compare returns a negative number when row a ranks lower than row b,
+	// 0 when they are tied and a positive number otherwise
+	compare := func(a, b int) float64 {
+		for _, j := range priority {
+			if d := (m.At(a, j) - m.At(b, j)) * math.Copysign(1, weights[j]); d != 0 {
+				return d
+			}
+		}
+		return 0
+	}
+
+	order := make([]int, len(rows))
+	for k := range order {
+		order[k] = k
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return compare(rows[order[a]], rows[order[b]]) < 0
+	})
+
+	ranks := make([]float64, len(rows))
+	below := 0
+	for pos, k := range order {
+		if pos > 0 && compare(rows[k], rows[order[pos-1]]) != 0 {
+			below = pos
+		}
+		ranks[k] = float64(below) / float64(len(rows))
+	}
+	return ranks, nil
+}
@@ -0,0 +1,281 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package score
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+
+	mx "code.google.com/p/biogo.matrix"
+
+	"github.com/DevMine/api-server/model"
+)
+
+// epsilon is the tolerance used when comparing computed ranks.
+const epsilon = 1e-9
+
+// rankersMatrix is a small scores matrix of 4 users and 2 features. User 3
+// has the best score on both features.
+var rankersMatrix = [][]float64{
+	{4, 0},
+	{0, 3},
+	{2, 1},
+	{4, 3},
+}
+
+func newMatrix(t *testing.T, scores [][]float64) mx.Matrix {
+	m, err := mx.NewDense(scores)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// rankOrder returns the indexes of ranks sorted by decreasing rank, ties
+// being kept in index order.
+func rankOrder(ranks []float64) []int {
+	order := make([]int, len(ranks))
+	for k := range order {
+		order[k] = k
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return ranks[order[a]] > ranks[order[b]]
+	})
+	return order
+}
+
+type rankerTest struct {
+	name    string
+	scores  [][]float64
+	rows    []int
+	weights []float64
+	opts    model.RankingOptions
+
+	// order is the expected order of the users, from the best to the worst,
+	// and ranks, when set, the expected ranks.
+	order []int
+	ranks []float64
+}
+
+func runRankerTests(t *testing.T, ranker Ranker, tests []rankerTest) {
+	for _, tt := range tests {
+		ranks, err := ranker.Rank(newMatrix(t, tt.scores), tt.rows, tt.weights, tt.opts)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		if order := rankOrder(ranks); !reflect.DeepEqual(order, tt.order) {
+			t.Errorf("%s: got order %v (ranks %v), want %v", tt.name, order, ranks, tt.order)
+		}
+		if tt.ranks == nil {
+			continue
+		}
+		if len(ranks) != len(tt.ranks) {
+			t.Errorf("%s: got %d ranks, want %d", tt.name, len(ranks), len(tt.ranks))
+			continue
+		}
+		for k := range ranks {
+			if math.Abs(ranks[k]-tt.ranks[k]) > epsilon {
+				t.Errorf("%s: got ranks %v, want %v", tt.name, ranks, tt.ranks)
+				break
+			}
+		}
+	}
+}
+
+func TestWeightedSum(t *testing.T) {
+	runRankerTests(t, weightedSum{}, []rankerTest{
+		{
+			name:    "all rows",
+			scores:  rankersMatrix,
+			weights: []float64{1, 1},
+			order:   []int{3, 0, 1, 2},
+			ranks:   []float64{4, 3, 3, 7},
+		},
+		{
+			name:    "selected rows",
+			scores:  rankersMatrix,
+			rows:    []int{2, 1},
+			weights: []float64{1, 2},
+			order:   []int{1, 0},
+			ranks:   []float64{4, 6},
+		},
+		{
+			name:    "negative weight",
+			scores:  rankersMatrix,
+			weights: []float64{1, -1},
+			order:   []int{0, 2, 3, 1},
+			ranks:   []float64{4, -3, 1, 1},
+		},
+	})
+}
+
+func TestLpDistance(t *testing.T) {
+	// The ideal point is (4, 3) with positive weights and (4, 0) when the
+	// second feature is minimized.
+	runRankerTests(t, lpDistance{}, []rankerTest{
+		{
+			name:    "euclidean distance by default",
+			scores:  rankersMatrix,
+			weights: []float64{1, 1},
+			order:   []int{3, 2, 0, 1},
+			ranks:   []float64{1.0 / 4, 1.0 / 5, 1 / (1 + math.Sqrt(8)), 1},
+		},
+		{
+			name:    "manhattan distance",
+			scores:  rankersMatrix,
+			weights: []float64{1, 1},
+			opts:    model.RankingOptions{P: 1},
+			order:   []int{3, 0, 1, 2},
+			ranks:   []float64{1.0 / 4, 1.0 / 5, 1.0 / 5, 1},
+		},
+		{
+			name:    "weighted distance",
+			scores:  rankersMatrix,
+			weights: []float64{4, 1},
+			order:   []int{3, 0, 2, 1},
+			ranks:   []float64{1.0 / 4, 1.0 / 9, 1 / (1 + math.Sqrt(20)), 1},
+		},
+		{
+			name:    "negative weight",
+			scores:  rankersMatrix,
+			weights: []float64{1, -1},
+			order:   []int{0, 2, 3, 1},
+			ranks:   []float64{1, 1.0 / 6, 1 / (1 + math.Sqrt(5)), 1.0 / 4},
+		},
+		{
+			name:    "ideal point among selected rows",
+			scores:  rankersMatrix,
+			rows:    []int{0, 2},
+			weights: []float64{1, 1},
+			order:   []int{0, 1},
+			ranks:   []float64{1.0 / 2, 1.0 / 3},
+		},
+	})
+}
+
+func TestTopsis(t *testing.T) {
+	// Columns are normalized by their Euclidean norm, 6 and sqrt(19).
+	n0, n1 := 6.0, math.Sqrt(19)
+	closeness := func(dBest, dWorst float64) float64 {
+		return dWorst / (dBest + dWorst)
+	}
+
+	runRankerTests(t, topsis{}, []rankerTest{
+		{
+			name:    "positive weights",
+			scores:  rankersMatrix,
+			weights: []float64{1, 1},
+			order:   []int{3, 1, 0, 2},
+			ranks: []float64{
+				closeness(3/n1, 4/n0),
+				closeness(4/n0, 3/n1),
+				closeness(math.Hypot(2/n0, 2/n1), math.Hypot(2/n0, 1/n1)),
+				1,
+			},
+		},
+		{
+			name:    "negative weight",
+			scores:  rankersMatrix,
+			weights: []float64{1, -1},
+			order:   []int{0, 2, 3, 1},
+			ranks: []float64{
+				1,
+				0,
+				closeness(math.Hypot(2/n0, 1/n1), math.Hypot(2/n0, 2/n1)),
+				closeness(3/n1, 4/n0),
+			},
+		},
+		{
+			name:    "norms of selected rows",
+			scores:  rankersMatrix,
+			rows:    []int{0, 2},
+			weights: []float64{1, 1},
+			order:   []int{1, 0},
+			ranks:   []float64{closeness(1, 1/math.Sqrt(5)), closeness(1/math.Sqrt(5), 1)},
+		},
+		{
+			name:    "identical users",
+			scores:  [][]float64{{1, 1}, {1, 1}},
+			weights: []float64{1, 1},
+			order:   []int{0, 1},
+			ranks:   []float64{0, 0},
+		},
+	})
+}
+
+func TestLexicographic(t *testing.T) {
+	// Users 0 and 3 have the same scores.
+	scores := [][]float64{
+		{1, 5},
+		{2, 1},
+		{2, 3},
+		{1, 5},
+		{0, 9},
+	}
+
+	runRankerTests(t, lexicographic{}, []rankerTest{
+		{
+			name:    "first feature first",
+			scores:  scores,
+			weights: []float64{2, 1},
+			order:   []int{2, 1, 0, 3, 4},
+			ranks:   []float64{0.2, 0.6, 0.8, 0.2, 0},
+		},
+		{
+			name:    "second feature first",
+			scores:  scores,
+			weights: []float64{1, 2},
+			order:   []int{4, 0, 3, 2, 1},
+			ranks:   []float64{0.4, 0, 0.2, 0.4, 0.8},
+		},
+		{
+			name:    "ties broken by a minimized feature",
+			scores:  scores,
+			weights: []float64{2, -1},
+			order:   []int{1, 2, 0, 3, 4},
+			ranks:   []float64{0.2, 0.8, 0.6, 0.2, 0},
+		},
+		{
+			name:    "ignored feature",
+			scores:  scores,
+			weights: []float64{0, 1},
+			order:   []int{4, 0, 3, 2, 1},
+			ranks:   []float64{0.4, 0, 0.2, 0.4, 0.8},
+		},
+		{
+			name:    "no feature",
+			scores:  scores,
+			weights: []float64{0, 0},
+			order:   []int{0, 1, 2, 3, 4},
+			ranks:   []float64{0, 0, 0, 0, 0},
+		},
+		{
+			name:    "selected rows",
+			scores:  scores,
+			rows:    []int{3, 1, 0},
+			weights: []float64{2, 1},
+			order:   []int{1, 0, 2},
+			ranks:   []float64{0, 2.0 / 3, 0},
+		},
+	})
+}
+
+func TestRankers(t *testing.T) {
+	want := []string{"lexicographic", "lp-distance", "topsis", "weighted-sum"}
+	if got := Rankers(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got rankers %v, want %v", got, want)
+	}
+
+	if !IsRanker("") {
+		t.Error("the empty name does not designate the default ranker")
+	}
+	if IsRanker("unknown") {
+		t.Error("unknown ranker reported as registered")
+	}
+}
@@ -7,7 +7,7 @@
 package score
 
 import (
-	"database/sql"
+	"fmt"
 	"sort"
 
 	mx "code.google.com/p/biogo.matrix"
@@ -16,20 +16,31 @@ import (
 	"github.com/DevMine/api-server/model"
 )
 
-// constructWeightVector creates the weight vector from default weight values
-// for each features from the database. Default weight values are overwritten
-// with the values in "featsWeightQuery". Key value of featsWeightQuery
-// corresponds to the 'name' column in the features table.
-func constructWeightVector(featsWeightQuery map[string]int64) (*mx.Dense, error) {
-	features := cache.GetFeatures()
-
-	weightVector := make([][]float64, len(features))
+// effectiveWeights returns the weight of each feature: the weight given in
+// "featsWeightQuery" or, if none, the default weight of the feature. Key value
+// of featsWeightQuery corresponds to the 'name' column in the features table.
+// The returned slice tells, for each feature, whether the default weight is
+// used.
+func effectiveWeights(features []model.Feature, featsWeightQuery map[string]float64) ([]float64, []bool) {
+	weights := make([]float64, len(features))
+	defaults := make([]bool, len(features))
 	for i, f := range features {
 		w, ok := featsWeightQuery[*f.Name]
 		if !ok {
-			w = *f.DefaultWeight
+			w = float64(*f.DefaultWeight)
 		}
-		weightVector[i] = []float64{float64(w)}
+		weights[i] = w
+		defaults[i] = !ok
+	}
+	return weights, defaults
+}
+
+// constructWeightVector creates the weight vector, as a column matrix, from
+// the weight of each feature.
+func constructWeightVector(weights []float64) (*mx.Dense, error) {
+	weightVector := make([][]float64, len(weights))
+	for i, w := range weights {
+		weightVector[i] = []float64{w}
 	}
 
 	w, err := mx.NewDense(weightVector)
@@ -49,29 +60,130 @@ func computeRanks(scores mx.Matrix, weightVector mx.Matrix) *mx.Dense {
 	return res
 }
 
-// Rank returns search results, sorted by rank.
-func Rank(db *sql.DB, featsWeightQuery map[string]int64) (model.SearchResults, error) {
-	sm := cache.GetScoresMatrix()
-	uv := cache.GetUsersVector()
+// Rank returns search results for query q, sorted by rank, computed from the
+// given cache snapshot with the ranker named in the query. Using a single
+// snapshot ensures that the scores matrix and the users vector are
+// consistent with each other, even if the cache gets reloaded.
+func Rank(snap *cache.Snapshot, q *model.SearchQuery) (model.SearchResults, error) {
+	ranked, err := rankRows(snap, q)
+	if err != nil {
+		return nil, err
+	}
+
+	uv := snap.UsersVector()
+	results := make(model.SearchResults, len(ranked))
+	for k, rr := range ranked {
+		results[k] = model.SearchResult{User: uv[rr.row], Rank: rr.rank}
+	}
+
+	sort.Stable(sort.Reverse(results))
+
+	return results, nil
+}
+
+// rankedRow is the rank of the user at a given row of the scores matrix.
+type rankedRow struct {
+	row  int
+	rank float64
+}
+
+// rankRows ranks the users satisfying the filters of query q, with the ranker
+// named in the query. Users ranked lower than the minimum rank of the query
+// are left out. Users are returned in the order of their rows.
+func rankRows(snap *cache.Snapshot, q *model.SearchQuery) ([]rankedRow, error) {
+	ranker, ok := lookupRanker(q.Ranking.Ranker)
+	if !ok {
+		return nil, fmt.Errorf("unknown ranker: %s", q.Ranking.Ranker)
+	}
 
-	// weight vector
-	w, err := constructWeightVector(featsWeightQuery)
+	nm, err := normalizedMatrix(snap, q.Ranking.Normalization)
 	if err != nil {
 		return nil, err
 	}
 
-	// rank matrix
-	rm := computeRanks(sm, w)
+	// users are filtered before being ranked
+	rows := selectRows(snap, q.Filters)
+	weights, _ := effectiveWeights(snap.Features(), q.Weights)
 
-	rows, _ := rm.Dims()
-	results := make(model.SearchResults, rows)
+	ranks, err := ranker.Rank(nm, rows, weights, q.Ranking)
+	if err != nil {
+		return nil, err
+	}
 
-	for i := 0; i < rows; i++ {
-		results[i].User = uv[i]
-		results[i].Rank = rm.Row(i)[0]
+	ranked := make([]rankedRow, 0, len(ranks))
+	for k, rank := range ranks {
+		if q.Ranking.MinRank != nil && rank < *q.Ranking.MinRank {
+			continue
+		}
+
+		row := k
+		if rows != nil {
+			row = rows[k]
+		}
+		ranked = append(ranked, rankedRow{row: row, rank: rank})
 	}
 
-	sort.Sort(sort.Reverse(results))
+	return ranked, nil
+}
 
-	return results, nil
+// dotRow computes the dot product between the given row of matrix m and
+// vector v.
+func dotRow(m mx.Matrix, row int, v []float64) float64 {
+	var sum float64
+	for j, x := range v {
+		if x != 0 {
+			sum += m.At(row, j) * x
+		}
+	}
+	return sum
+}
+
+// Explain sets the explanation of the given search results, which must have
+// been computed from the same snapshot for query q. Only the features with a
+// non-zero weight, which are the ones contributing to the rank, are part of
+// the explanation.
+func Explain(snap *cache.Snapshot, q *model.SearchQuery, results model.SearchResults) error {
+	features := snap.Features()
+	sm := snap.ScoresMatrix()
+	weights, defaults := effectiveWeights(features, q.Weights)
+
+	nm, err := normalizedMatrix(snap, q.Ranking.Normalization)
+	if err != nil {
+		return err
+	}
+	isNormalized := nm != mx.Matrix(sm)
+
+	for i := range results {
+		row, ok := snap.UserRow(*results[i].ID)
+		if !ok {
+			continue
+		}
+
+		expl := make([]model.FeatureContribution, 0, len(features))
+		for col, f := range features {
+			if weights[col] == 0 {
+				continue
+			}
+
+			fc := model.FeatureContribution{
+				Feature:       *f.Name,
+				Score:         sm.At(row, col),
+				Weight:        weights[col],
+				DefaultWeight: defaults[col],
+			}
+			score := fc.Score
+			if isNormalized {
+				score = nm.At(row, col)
+				fc.NormalizedScore = &score
+			}
+			// adding 0 turns the negative zeros of null scores with
+			// negative weights into zeros
+			fc.Contribution = score*weights[col] + 0
+
+			expl = append(expl, fc)
+		}
+		results[i].Explanation = expl
+	}
+
+	return nil
 }
@@ -0,0 +1,66 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package score
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/DevMine/api-server/cache"
+	"github.com/DevMine/api-server/model"
+)
+
+// loadSnapshot loads a cache snapshot with users u0 to uN, N being the
+// number of rows of scores, and features named after the letters of the
+// alphabet, a to the number of columns of scores, with a default weight of 1.
+func loadSnapshot(t *testing.T, scores [][]float64, profiles []cache.Profile) *cache.Snapshot {
+	var features []model.Feature
+	for j := range scores[0] {
+		id, name, weight := int64(j+1), string(rune('a'+j)), int64(1)
+		features = append(features, model.Feature{ID: &id, Name: &name, DefaultWeight: &weight})
+	}
+
+	users := make([]model.User, len(scores))
+	for i := range users {
+		id, username := int64(i+1), fmt.Sprintf("u%d", i)
+		users[i] = model.User{ID: &id, Username: &username}
+	}
+
+	err := cache.LoadFixture(cache.Fixture{
+		Features: features,
+		Users:    users,
+		Scores:   scores,
+		Profiles: profiles,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cache.Current()
+}
+
+// usernames returns the usernames of the users of the given results.
+func usernames(results model.SearchResults) []string {
+	names := make([]string, len(results))
+	for i, r := range results {
+		names[i] = *r.Username
+	}
+	return names
+}
+
+func TestRank(t *testing.T) {
+	snap := loadSnapshot(t, rankersMatrix, nil)
+
+	results, err := Rank(snap, &model.SearchQuery{Weights: map[string]float64{"b": 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ranks are 4, 6, 4 and 10
+	want := []string{"u3", "u1", "u0", "u2"}
+	if got := usernames(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package score
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+
+	mx "code.google.com/p/biogo.matrix"
+
+	"github.com/DevMine/api-server/cache"
+	"github.com/DevMine/api-server/model"
+)
+
+// Metrics used to compare the scores of users.
+const (
+	// MetricCosine compares the directions of the score vectors, regardless
+	// of their magnitudes.
+	MetricCosine = "cosine"
+
+	// MetricEuclidean computes the Euclidean distance between score vectors.
+	MetricEuclidean = "euclidean"
+)
+
+// metrics maps the metrics to the function computing the distance and the
+// similarity between rows a and b of matrix m, over the given columns.
+var metrics = map[string]func(m mx.Matrix, a, b int, cols []int) (dist, sim float64){
+	MetricCosine: func(m mx.Matrix, a, b int, cols []int) (float64, float64) {
+		var dot, normA, normB float64
+		for _, j := range cols {
+			va, vb := m.At(a, j), m.At(b, j)
+			dot += va * vb
+			normA += va * va
+			normB += vb * vb
+		}
+
+		var sim float64
+		if normA > 0 && normB > 0 {
+			sim = dot / math.Sqrt(normA*normB)
+		}
+		return 1 - sim, sim
+	},
+	MetricEuclidean: func(m mx.Matrix, a, b int, cols []int) (float64, float64) {
+		var sum float64
+		for _, j := range cols {
+			d := m.At(a, j) - m.At(b, j)
+			sum += d * d
+		}
+
+		dist := math.Sqrt(sum)
+		return dist, 1 / (1 + dist)
+	},
+}
+
+// IsMetric reports whether metric is a valid similarity metric.
+func IsMetric(metric string) bool {
+	_, ok := metrics[metric]
+	return ok
+}
+
+// similarRow is a user compared to the reference user of Similar.
+type similarRow struct {
+	row       int
+	dist, sim float64
+}
+
+// closer reports whether the user of a is more similar to the reference user
+// than the user of b. Ties are broken by row.
+func (a similarRow) closer(b similarRow) bool {
+	return a.dist < b.dist || (a.dist == b.dist && a.row < b.row)
+}
+
+// similarHeap is a heap of users whose root is the least similar user. It
+// implements heap.Interface.
+type similarHeap []similarRow
+
+func (h similarHeap) Len() int            { return len(h) }
+func (h similarHeap) Less(i, j int) bool  { return h[j].closer(h[i]) }
+func (h similarHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *similarHeap) Push(x interface{}) { *h = append(*h, x.(similarRow)) }
+func (h *similarHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// Similar returns the k users of the snapshot the most similar to the user at
+// the given row of the scores matrix, the user itself excluded, sorted from
+// the most to the least similar. Users are compared with the given metric
+// over the given columns of the scores matrix, normalized with the given
+// mode. All columns are used when cols is empty.
+// Only the k most similar users are kept while comparing users, so that the
+// whole population is never sorted.
+func Similar(snap *cache.Snapshot, row int, metric, normalization string, cols []int, k int) (model.SimilarUsers, error) {
+	m, err := normalizedMatrix(snap, normalization)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, nbCols := m.Dims()
+	if len(cols) == 0 {
+		cols = make([]int, nbCols)
+		for j := range cols {
+			cols[j] = j
+		}
+	}
+	if k > rows-1 {
+		k = rows - 1
+	}
+	if k <= 0 {
+		return model.SimilarUsers{}, nil
+	}
+
+	compare := metrics[metric]
+
+	h := make(similarHeap, 0, k)
+	for i := 0; i < rows; i++ {
+		if i == row {
+			continue
+		}
+
+		sr := similarRow{row: i}
+		sr.dist, sr.sim = compare(m, row, i, cols)
+		if len(h) < k {
+			heap.Push(&h, sr)
+		} else if sr.closer(h[0]) {
+			h[0] = sr
+			heap.Fix(&h, 0)
+		}
+	}
+
+	sort.Slice(h, func(i, j int) bool {
+		return h[i].closer(h[j])
+	})
+
+	uv := snap.UsersVector()
+	similar := make(model.SimilarUsers, len(h))
+	for i, sr := range h {
+		similar[i] = model.SimilarUser{User: uv[sr.row], Distance: sr.dist, Similarity: sr.sim}
+	}
+
+	return similar, nil
+}
@@ -0,0 +1,41 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package score
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSimilar(t *testing.T) {
+	// the Euclidean distances to u0 are 5, 1, 3, 1 and 10
+	snap := loadSnapshot(t, [][]float64{{0}, {5}, {1}, {3}, {1}, {10}}, nil)
+
+	tests := []struct {
+		k    int
+		want []string
+	}{
+		{1, []string{"u2"}},
+		{3, []string{"u2", "u4", "u3"}},
+		{5, []string{"u2", "u4", "u3", "u1", "u5"}},
+		{10, []string{"u2", "u4", "u3", "u1", "u5"}},
+		{0, []string{}},
+	}
+
+	for _, tt := range tests {
+		similar, err := Similar(snap, 0, MetricEuclidean, NormNone, nil, tt.k)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := make([]string, len(similar))
+		for i, su := range similar {
+			got[i] = *su.Username
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("k = %d: got %v, want %v", tt.k, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package score
+
+import (
+	"sort"
+	"strings"
+
+	mx "code.google.com/p/biogo.matrix"
+
+	"github.com/DevMine/api-server/cache"
+	"github.com/DevMine/api-server/model"
+)
+
+// skylineColumn is a column of the scores matrix considered by a skyline
+// query, along with the direction in which scores are better: 1 when higher
+// scores are better, -1 otherwise.
+type skylineColumn struct {
+	col       int
+	direction float64
+}
+
+// skylineColumns returns the columns corresponding to the features of a
+// skyline query. Features prefixed by '-' are minimized.
+func skylineColumns(snap *cache.Snapshot, features []string) []skylineColumn {
+	cols := make([]skylineColumn, 0, len(features))
+	for _, feat := range features {
+		sc := skylineColumn{direction: 1}
+		if strings.HasPrefix(feat, "-") {
+			feat, sc.direction = feat[1:], -1
+		}
+
+		col, ok := snap.FeatureColumn(feat)
+		if !ok {
+			continue
+		}
+		sc.col = col
+		cols = append(cols, sc)
+	}
+	return cols
+}
+
+// dominates reports whether the user at row a dominates the user at row b,
+// that is whether a is at least as good as b on all columns and strictly
+// better on at least one.
+func dominates(m mx.Matrix, a, b int, cols []skylineColumn) bool {
+	strictly := false
+	for _, sc := range cols {
+		d := (m.At(a, sc.col) - m.At(b, sc.col)) * sc.direction
+		if d < 0 {
+			return false
+		}
+		if d > 0 {
+			strictly = true
+		}
+	}
+	return strictly
+}
+
+// Skyline returns the users satisfying the filters of query q by skyline
+// layer: layer 1 is made of the users which are not dominated by any other
+// user on the features of the query, layer 2 of the users which are only
+// dominated by users of layer 1, and so on. Within a layer, users are sorted
+// by rank, as computed by the ranker of the query.
+// Layers are computed until at least n users are returned. If q asks for a
+// given layer, only the users of this layer are returned.
+// The total number of users the layers are computed from is returned as well.
+func Skyline(snap *cache.Snapshot, q *model.SearchQuery, n int) (model.SearchResults, int, error) {
+	ranked, err := rankRows(snap, q)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sm := snap.ScoresMatrix()
+	cols := skylineColumns(snap, q.Skyline.Features)
+
+	// A user can only be dominated by users whose sum of scores, in the
+	// direction of each column, is strictly higher. Users are thus sorted by
+	// decreasing sum so that each user only needs to be compared to the
+	// users of the current layer which precede it.
+	sums := make([]float64, len(ranked))
+	for k, rr := range ranked {
+		for _, sc := range cols {
+			sums[k] += sm.At(rr.row, sc.col) * sc.direction
+		}
+	}
+	remaining := make([]int, len(ranked))
+	for k := range remaining {
+		remaining[k] = k
+	}
+	sort.SliceStable(remaining, func(a, b int) bool {
+		return sums[remaining[a]] > sums[remaining[b]]
+	})
+
+	total := len(ranked)
+	uv := snap.UsersVector()
+
+	var results model.SearchResults
+	for layer := 1; len(remaining) > 0; layer++ {
+		var sky, rest []int
+		for _, k := range remaining {
+			dominated := false
+			for _, s := range sky {
+				if dominates(sm, ranked[s].row, ranked[k].row, cols) {
+					dominated = true
+					break
+				}
+			}
+			if dominated {
+				rest = append(rest, k)
+			} else {
+				sky = append(sky, k)
+			}
+		}
+		remaining = rest
+
+		if q.Skyline.Layer > 0 && layer < q.Skyline.Layer {
+			continue
+		}
+
+		layerResults := make(model.SearchResults, len(sky))
+		for i, k := range sky {
+			layerResults[i] = model.SearchResult{
+				User:  uv[ranked[k].row],
+				Rank:  ranked[k].rank,
+				Layer: layer,
+			}
+		}
+		sort.Stable(sort.Reverse(layerResults))
+		results = append(results, layerResults...)
+
+		if q.Skyline.Layer > 0 {
+			return results, len(results), nil
+		}
+		if len(results) >= n {
+			break
+		}
+	}
+
+	if q.Skyline.Layer > 0 {
+		// the requested layer does not exist
+		return model.SearchResults{}, 0, nil
+	}
+
+	return results, total, nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package score
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DevMine/api-server/model"
+)
+
+// skylineScores are the scores of 8 users on features a and b. With a weight
+// of 2 for a, the ranks are 8, 7, 9, 6, 11, 3, 7 and 0.
+// The layers are:
+//
+//	1: u4 (5, 1), u2 (3, 3), u1 (1, 5) and u6 (1, 5)
+//	2: u0 (4, 0), dominated by u4 only, and u3 (2, 2), dominated by u2
+//	3: u5 (1, 1), dominated by u3 among others
+//	4: u7 (0, 0), dominated by every other user
+var skylineScores = [][]float64{
+	{4, 0},
+	{1, 5},
+	{3, 3},
+	{2, 2},
+	{5, 1},
+	{1, 1},
+	{1, 5},
+	{0, 0},
+}
+
+func TestDominates(t *testing.T) {
+	m := newMatrix(t, [][]float64{
+		{2, 2},
+		{1, 2},
+		{2, 2},
+		{3, 1},
+	})
+	up := []skylineColumn{{col: 0, direction: 1}, {col: 1, direction: 1}}
+	down := []skylineColumn{{col: 0, direction: 1}, {col: 1, direction: -1}}
+
+	tests := []struct {
+		a, b int
+		cols []skylineColumn
+		want bool
+	}{
+		{0, 1, up, true},
+		{1, 0, up, false},
+		{0, 2, up, false},
+		{0, 3, up, false},
+		{3, 0, up, false},
+		{3, 0, down, true},
+		{3, 1, down, true},
+		{0, 1, down, true},
+		{0, 1, nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := dominates(m, tt.a, tt.b, tt.cols); got != tt.want {
+			t.Errorf("dominates(%d, %d, %v): got %v, want %v", tt.a, tt.b, tt.cols, got, tt.want)
+		}
+	}
+}
+
+func TestSkyline(t *testing.T) {
+	snap := loadSnapshot(t, skylineScores, nil)
+	weights := map[string]float64{"a": 2}
+
+	tests := []struct {
+		name     string
+		features []string
+		layer    int
+		n        int
+		users    []string
+		layers   []int
+		total    int
+	}{
+		{
+			name:     "all layers",
+			features: []string{"a", "b"},
+			n:        100,
+			users:    []string{"u4", "u2", "u1", "u6", "u0", "u3", "u5", "u7"},
+			layers:   []int{1, 1, 1, 1, 2, 2, 3, 4},
+			total:    8,
+		},
+		{
+			name:     "layers up to n users",
+			features: []string{"a", "b"},
+			n:        5,
+			users:    []string{"u4", "u2", "u1", "u6", "u0", "u3"},
+			layers:   []int{1, 1, 1, 1, 2, 2},
+			total:    8,
+		},
+		{
+			name:     "given layer",
+			features: []string{"a", "b"},
+			layer:    2,
+			n:        1,
+			users:    []string{"u0", "u3"},
+			layers:   []int{2, 2},
+			total:    2,
+		},
+		{
+			name:     "missing layer",
+			features: []string{"a", "b"},
+			layer:    5,
+			n:        100,
+			users:    []string{},
+			layers:   []int{},
+		},
+		{
+			name:     "minimized feature",
+			features: []string{"a", "-b"},
+			n:        100,
+			users:    []string{"u4", "u0", "u2", "u3", "u5", "u7", "u1", "u6"},
+			layers:   []int{1, 1, 2, 2, 2, 2, 3, 3},
+			total:    8,
+		},
+		{
+			name:     "single feature",
+			features: []string{"b"},
+			layer:    1,
+			n:        100,
+			users:    []string{"u1", "u6"},
+			layers:   []int{1, 1},
+			total:    2,
+		},
+	}
+
+	for _, tt := range tests {
+		q := &model.SearchQuery{
+			Mode:    model.SearchModeSkyline,
+			Weights: weights,
+			Skyline: model.SkylineOptions{Features: tt.features, Layer: tt.layer},
+		}
+
+		results, total, err := Skyline(snap, q, tt.n)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		layers := make([]int, len(results))
+		for i, r := range results {
+			layers[i] = r.Layer
+		}
+		if got := usernames(results); !reflect.DeepEqual(got, tt.users) {
+			t.Errorf("%s: got users %v, want %v", tt.name, got, tt.users)
+		}
+		if !reflect.DeepEqual(layers, tt.layers) {
+			t.Errorf("%s: got layers %v, want %v", tt.name, layers, tt.layers)
+		}
+		if total != tt.total {
+			t.Errorf("%s: got total %d, want %d", tt.name, total, tt.total)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package score
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+
+	"github.com/DevMine/api-server/cache"
+	"github.com/DevMine/api-server/model"
+)
+
+// MaxRequirements is the maximum number of required features of a team
+// query.
+const MaxRequirements = 64
+
+// maxSeedsPerTeam is the number of users tried as the first member of a team,
+// per requested team.
+const maxSeedsPerTeam = 4
+
+// teamCandidate is a user who covers at least one required feature.
+type teamCandidate struct {
+	row int
+
+	// covers has the bit of each required feature the user covers set.
+	covers uint64
+
+	// strength is the score of the user for each required feature, divided
+	// by the highest score of the feature.
+	strength []float64
+}
+
+// gain returns the number of required features among uncovered that the
+// candidate covers, along with its strength on these features.
+func (tc *teamCandidate) gain(uncovered uint64) (int, float64) {
+	covers := tc.covers & uncovered
+	var strength float64
+	for j := range tc.strength {
+		if covers&(1<<uint(j)) != 0 {
+			strength += tc.strength[j]
+		}
+	}
+	return bits.OnesCount64(covers), strength
+}
+
+// better reports whether a gain of n features with the given strength is
+// better than a gain of bestN features with bestStrength.
+func better(n int, strength float64, bestN int, bestStrength float64) bool {
+	return n > bestN || (n == bestN && strength > bestStrength)
+}
+
+// Teams returns up to q.Count candidate teams of at most q.MaxSize users
+// which together cover the required features of query q: a user covers a
+// feature when the user's score is at least the required minimum.
+// Teams are built with a greedy set cover, each time adding the user covering
+// the most features not covered yet, the stronger the better. Each candidate
+// team starts from a different user, among the users covering the most
+// features. Teams are sorted by decreasing number of covered features, then
+// by increasing size and by decreasing rank.
+func Teams(snap *cache.Snapshot, q *model.TeamQuery) (model.Teams, error) {
+	if len(q.Requirements) > MaxRequirements {
+		return nil, fmt.Errorf("too many requirements: %d", len(q.Requirements))
+	}
+
+	reqs := make([]string, 0, len(q.Requirements))
+	for feat := range q.Requirements {
+		reqs = append(reqs, feat)
+	}
+	sort.Strings(reqs)
+
+	cols := make([]int, len(reqs))
+	for j, feat := range reqs {
+		col, ok := snap.FeatureColumn(feat)
+		if !ok {
+			return nil, fmt.Errorf("unknown feature: %s", feat)
+		}
+		cols[j] = col
+	}
+
+	maxSize := q.MaxSize
+	if maxSize <= 0 {
+		maxSize = len(reqs)
+	}
+
+	candidates := teamCandidates(snap, q, reqs, cols)
+
+	// the users covering the most features are the seeds of the teams
+	sort.SliceStable(candidates, func(a, b int) bool {
+		na, sa := candidates[a].gain(^uint64(0))
+		nb, sb := candidates[b].gain(^uint64(0))
+		return better(na, sa, nb, sb)
+	})
+
+	all := uint64(1)<<uint(len(reqs)) - 1
+	if len(reqs) == MaxRequirements {
+		all = ^uint64(0)
+	}
+
+	// several seeds may lead to the same team, but trying every candidate
+	// as a seed would be too expensive
+	seeds := len(candidates)
+	if seeds > maxSeedsPerTeam*q.Count {
+		seeds = maxSeedsPerTeam * q.Count
+	}
+
+	teams := make(model.Teams, 0)
+	seen := make(map[string]bool)
+	for seed := 0; seed < seeds && len(teams) < q.Count; seed++ {
+		members := []int{seed}
+		assigned := [][]int{covered(candidates[seed].covers & all)}
+		uncovered := all &^ candidates[seed].covers
+
+		for uncovered != 0 && len(members) < maxSize {
+			best, bestN, bestStrength := -1, 0, 0.0
+			for k := range candidates {
+				if n, strength := candidates[k].gain(uncovered); better(n, strength, bestN, bestStrength) {
+					best, bestN, bestStrength = k, n, strength
+				}
+			}
+			if best < 0 {
+				break
+			}
+
+			members = append(members, best)
+			assigned = append(assigned, covered(candidates[best].covers&uncovered))
+			uncovered &^= candidates[best].covers
+		}
+
+		key := teamKey(candidates, members)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		teams = append(teams, makeTeam(snap, q, reqs, cols, candidates, members, assigned, uncovered))
+	}
+
+	sort.SliceStable(teams, func(a, b int) bool {
+		ta, tb := teams[a], teams[b]
+		if len(ta.Missing) != len(tb.Missing) {
+			return len(ta.Missing) < len(tb.Missing)
+		}
+		if len(ta.Members) != len(tb.Members) {
+			return len(ta.Members) < len(tb.Members)
+		}
+		return ta.Rank > tb.Rank
+	})
+
+	return teams, nil
+}
+
+// teamCandidates returns the users satisfying the filters of query q who
+// cover at least one of the required features.
+func teamCandidates(snap *cache.Snapshot, q *model.TeamQuery, reqs []string, cols []int) []teamCandidate {
+	sm := snap.ScoresMatrix()
+	stats := snap.ColumnStats()
+
+	var candidates []teamCandidate
+	for _, row := range allRows(sm, selectRows(snap, q.Filters)) {
+		tc := teamCandidate{row: row}
+		for j, col := range cols {
+			score := sm.At(row, col)
+			if score < q.Requirements[reqs[j]] {
+				continue
+			}
+			tc.covers |= 1 << uint(j)
+			if tc.strength == nil {
+				tc.strength = make([]float64, len(cols))
+			}
+			if max := stats[col].Max; max > 0 {
+				tc.strength[j] = score / max
+			}
+		}
+		if tc.covers != 0 {
+			candidates = append(candidates, tc)
+		}
+	}
+	return candidates
+}
+
+// covered returns the indexes of the required features whose bit is set in
+// covers.
+func covered(covers uint64) []int {
+	var js []int
+	for j := 0; covers != 0; j++ {
+		if covers&1 != 0 {
+			js = append(js, j)
+		}
+		covers >>= 1
+	}
+	return js
+}
+
+// teamKey returns a key identifying the given members, regardless of their
+// order.
+func teamKey(candidates []teamCandidate, members []int) string {
+	rows := make([]int, len(members))
+	for i, k := range members {
+		rows[i] = candidates[k].row
+	}
+	sort.Ints(rows)
+	return fmt.Sprint(rows)
+}
+
+// makeTeam builds a team from its members, given as indexes of candidates,
+// along with the features assigned to each member.
+func makeTeam(snap *cache.Snapshot, q *model.TeamQuery, reqs []string, cols []int,
+	candidates []teamCandidate, members []int, assigned [][]int, uncovered uint64) model.Team {
+
+	sm := snap.ScoresMatrix()
+	uv := snap.UsersVector()
+
+	team := model.Team{Members: make([]model.TeamMember, len(members))}
+	for i, k := range members {
+		tc := candidates[k]
+		tm := model.TeamMember{User: uv[tc.row]}
+		for _, j := range assigned[i] {
+			tm.Contributions = append(tm.Contributions, model.TeamContribution{
+				Feature: reqs[j],
+				Score:   sm.At(tc.row, cols[j]),
+				Min:     q.Requirements[reqs[j]],
+			})
+			team.Rank += tc.strength[j]
+		}
+		team.Members[i] = tm
+	}
+
+	team.Missing = make([]string, 0)
+	for _, j := range covered(uncovered) {
+		team.Missing = append(team.Missing, reqs[j])
+	}
+
+	return team
+}
@@ -0,0 +1,171 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package srv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+
+	"github.com/andybalholm/brotli"
+
+	"github.com/DevMine/api-server/util/httputil"
+)
+
+// encodings are the supported content codings, by order of preference.
+var encodings = []string{"br", "gzip"}
+
+// newEncoder creates a writer compressing data written to w using the given
+// content coding.
+func newEncoder(w io.Writer, encoding string) io.WriteCloser {
+	switch encoding {
+	case "br":
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression)
+	case "gzip":
+		return gzip.NewWriter(w)
+	}
+	panic("unsupported content coding: " + encoding)
+}
+
+// compressWriter is an http.ResponseWriter which compresses the response
+// body, provided that it is larger than a minimum size. The body is buffered
+// until the minimum size is reached or until the response is flushed. Close
+// must be called once the response is complete.
+type compressWriter struct {
+	http.ResponseWriter
+
+	encoding string
+	minSize  int
+
+	buf     bytes.Buffer
+	status  int
+	decided bool
+	encoder io.WriteCloser
+
+	// head is true for HEAD requests, whose response has the same headers
+	// as the one of a GET request but no body. discard is set when the body
+	// would have been compressed: it is then dropped rather than compressed.
+	head    bool
+	discard bool
+}
+
+// newCompressWriter wraps w so that the response body is compressed
+// according to the Accept-Encoding header of the request. If the client does
+// not accept any supported content coding, w is returned as is, with a no-op
+// close function. Responses to HEAD requests get the same Content-Encoding
+// header as responses to GET requests, without the body being compressed.
+func newCompressWriter(w http.ResponseWriter, r *http.Request, minSize int) (http.ResponseWriter, func()) {
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	enc := httputil.NegotiateEncoding(r.Header.Get("Accept-Encoding"), encodings)
+	if len(enc) == 0 {
+		return w, func() {}
+	}
+
+	cw := &compressWriter{ResponseWriter: w, encoding: enc, minSize: minSize, head: r.Method == "HEAD"}
+	return cw, cw.Close
+}
+
+// WriteHeader implements the http.ResponseWriter interface. The header is
+// actually written once it is known whether the body is compressed or not.
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.status == 0 {
+		cw.status = code
+	}
+}
+
+// Write implements the http.ResponseWriter interface.
+func (cw *compressWriter) Write(bs []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	if !cw.decided {
+		if cw.buf.Len()+len(bs) < cw.minSize {
+			return cw.buf.Write(bs)
+		}
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+	}
+
+	if cw.discard {
+		return len(bs), nil
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Write(bs)
+	}
+	return cw.ResponseWriter.Write(bs)
+}
+
+// decide writes the header and the buffered data, compressed or not.
+func (cw *compressWriter) decide(compress bool) error {
+	cw.decided = true
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	// responses with no body or already encoded are never compressed
+	h := cw.Header()
+	if cw.status < 200 || cw.status == http.StatusNoContent ||
+		cw.status == http.StatusNotModified || len(h.Get("Content-Encoding")) > 0 {
+		compress = false
+	}
+
+	if compress {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		if cw.head {
+			cw.discard = true
+		} else {
+			cw.encoder = newEncoder(cw.ResponseWriter, cw.encoding)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	if cw.buf.Len() == 0 || cw.discard {
+		cw.buf.Reset()
+		return nil
+	}
+	var err error
+	if cw.encoder != nil {
+		_, err = cw.encoder.Write(cw.buf.Bytes())
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf.Bytes())
+	}
+	cw.buf.Reset()
+	return err
+}
+
+// Flush implements the http.Flusher interface. Flushing a response which is
+// not compressed yet starts compressing it, as it is assumed to be streamed.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if err := cw.decide(cw.buf.Len() > 0); err != nil {
+			return
+		}
+	}
+
+	type flusher interface {
+		Flush() error
+	}
+	if f, ok := cw.encoder.(flusher); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close writes any buffered data and terminates the compressed stream.
+func (cw *compressWriter) Close() {
+	if !cw.decided {
+		cw.decide(false)
+	}
+	if cw.encoder != nil {
+		cw.encoder.Close()
+	}
+}
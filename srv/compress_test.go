@@ -0,0 +1,70 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package srv
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DevMine/api-server/config"
+)
+
+func TestCompressHeadMirrorsGet(t *testing.T) {
+	tests := []struct {
+		minSize  int
+		encoding string
+	}{
+		{10, "gzip"},
+		{1 << 20, ""},
+	}
+
+	for _, tt := range tests {
+		r := newTestRouter(t, 10, &config.Config{
+			Compression: config.CompressionConfig{Enabled: true, MinSize: tt.minSize},
+		})
+
+		responses := make(map[string]*httptest.ResponseRecorder)
+		for _, method := range []string{"GET", "HEAD"} {
+			req := httptest.NewRequest(method, "/features", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			w := serve(r, req)
+			responses[method] = w
+
+			if w.Code != http.StatusOK {
+				t.Errorf("%s with minimum size %d: got status %d, want %d",
+					method, tt.minSize, w.Code, http.StatusOK)
+			}
+			if enc := w.Header().Get("Content-Encoding"); enc != tt.encoding {
+				t.Errorf("%s with minimum size %d: got Content-Encoding %q, want %q",
+					method, tt.minSize, enc, tt.encoding)
+			}
+			if !strings.Contains(strings.Join(w.Header()["Vary"], ", "), "Accept-Encoding") {
+				t.Errorf("%s with minimum size %d: missing Vary: Accept-Encoding", method, tt.minSize)
+			}
+		}
+
+		get, head := responses["GET"], responses["HEAD"]
+		if head.Header().Get("ETag") != get.Header().Get("ETag") {
+			t.Errorf("minimum size %d: got HEAD ETag %q, want %q",
+				tt.minSize, head.Header().Get("ETag"), get.Header().Get("ETag"))
+		}
+		if len(tt.encoding) > 0 {
+			if head.Body.Len() > 0 {
+				t.Errorf("minimum size %d: got HEAD body of %d bytes, want none", tt.minSize, head.Body.Len())
+			}
+			zr, err := gzip.NewReader(get.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bs, err := io.ReadAll(zr); err != nil || !strings.Contains(string(bs), "followers_count") {
+				t.Errorf("minimum size %d: got GET body %q (%v), want features", tt.minSize, bs, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package srv
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DevMine/api-server/cache"
+)
+
+// defaultCacheControl is the Cache-Control header value used when none is
+// configured: clients may store responses but must revalidate them.
+const defaultCacheControl = "no-cache"
+
+// computeETag computes a weak entity tag for the response to request r,
+// given the generation of the cache the response is computed from and the
+// media type of the response. The method of the request is left out so that
+// HEAD and GET requests share the same entity tag.
+func computeETag(generation uint64, r *http.Request, mediaType string) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%d\n%s\n%s\n%s",
+		generation, mediaType, r.URL.Path, r.URL.Query().Encode())
+	return `W/"` + hex.EncodeToString(h.Sum(nil))[:20] + `"`
+}
+
+// etagMatch reports whether the entity tag etag is in the list of entity
+// tags given in an If-None-Match header, using the weak comparison function.
+func etagMatch(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified reports whether the conditional headers of request r allow
+// responding with 304 Not Modified. As specified in RFC 7232, If-Modified-Since
+// is ignored when If-None-Match is present.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); len(inm) > 0 {
+		return etagMatch(inm, etag)
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); len(ims) > 0 {
+		t, err := http.ParseTime(ims)
+		return err == nil && !lastModified.Truncate(time.Second).After(t)
+	}
+
+	return false
+}
+
+// checkCached sets the validators and Cache-Control headers of a response
+// computed from the cache and reports whether the request can be answered
+// with 304 Not Modified, provided that the handler successfully resolves the
+// requested resource.
+func checkCached(w http.ResponseWriter, r *http.Request, mediaType, cacheControl string) bool {
+	s := cache.Latest()
+	if s == nil {
+		return false
+	}
+
+	etag := computeETag(s.Generation(), r, mediaType)
+	lastModified := s.LoadedAt()
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", cacheControl)
+
+	return notModified(r, etag, lastModified)
+}
+
+// notModifiedWriter is an http.ResponseWriter which replaces a 200 OK response
+// with a 304 Not Modified response without body. Since the status code is
+// only known once the handler has resolved the requested resource, errors,
+// such as 404 Not Found for a missing resource, are sent as is.
+type notModifiedWriter struct {
+	http.ResponseWriter
+
+	wroteHeader bool
+	discard     bool
+}
+
+// WriteHeader implements the http.ResponseWriter interface.
+func (nw *notModifiedWriter) WriteHeader(code int) {
+	if nw.wroteHeader {
+		return
+	}
+	nw.wroteHeader = true
+
+	if code == http.StatusOK {
+		nw.discard = true
+		nw.Header().Del("Content-Length")
+		code = http.StatusNotModified
+	}
+	nw.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements the http.ResponseWriter interface.
+func (nw *notModifiedWriter) Write(bs []byte) (int, error) {
+	if !nw.wroteHeader {
+		nw.WriteHeader(http.StatusOK)
+	}
+	if nw.discard {
+		return len(bs), nil
+	}
+	return nw.ResponseWriter.Write(bs)
+}
+
+// Flush implements the http.Flusher interface.
+func (nw *notModifiedWriter) Flush() {
+	if f, ok := nw.ResponseWriter.(http.Flusher); ok && !nw.discard {
+		f.Flush()
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package srv
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DevMine/api-server/config"
+)
+
+func TestConditionalHeadAndGet(t *testing.T) {
+	r := newTestRouter(t, 3, &config.Config{})
+
+	get := serve(r, httptest.NewRequest("GET", "/users/user1/scores", nil))
+	head := serve(r, httptest.NewRequest("HEAD", "/users/user1/scores", nil))
+
+	etag := get.Header().Get("ETag")
+	if len(etag) == 0 {
+		t.Fatal("missing ETag header")
+	}
+	if head.Header().Get("ETag") != etag {
+		t.Errorf("got HEAD ETag %q, want GET ETag %q", head.Header().Get("ETag"), etag)
+	}
+
+	req := httptest.NewRequest("HEAD", "/users/user1/scores", nil)
+	req.Header.Set("If-None-Match", etag)
+	if w := serve(r, req); w.Code != http.StatusNotModified {
+		t.Errorf("HEAD with GET ETag: got status %d, want %d", w.Code, http.StatusNotModified)
+	}
+}
+
+func TestConditionalErrorHeaders(t *testing.T) {
+	r := newTestRouter(t, 3, &config.Config{})
+
+	tests := []struct {
+		url    string
+		header string
+		value  string
+	}{
+		{"/users/nobody/scores", "", ""},
+		{"/users/nobody/scores", "If-None-Match", "*"},
+		{"/users/nobody/rank", "If-None-Match", "*"},
+		{"/users/nobody/scores", "If-Modified-Since", "Fri, 01 Jan 2100 00:00:00 GMT"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.url, nil)
+		if len(tt.header) > 0 {
+			req.Header.Set(tt.header, tt.value)
+		}
+
+		w := serve(r, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s with %s %q: got status %d, want %d",
+				tt.url, tt.header, tt.value, w.Code, http.StatusNotFound)
+			continue
+		}
+		for _, h := range []string{"ETag", "Last-Modified"} {
+			if v := w.Header().Get(h); len(v) > 0 {
+				t.Errorf("%s: got %s header %q on error response, want none", tt.url, h, v)
+			}
+		}
+		if cc := w.Header().Get("Cache-Control"); cc != "no-store" {
+			t.Errorf("%s: got Cache-Control %q, want %q", tt.url, cc, "no-store")
+		}
+	}
+}
+
+func TestConditionalNotModified(t *testing.T) {
+	r := newTestRouter(t, 3, &config.Config{})
+
+	for _, url := range []string{"/users/user1/scores", "/users/user1/rank", "/search?q=%7B%7D"} {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("If-None-Match", "*")
+
+		w := serve(r, req)
+		if w.Code != http.StatusNotModified {
+			t.Errorf("%s: got status %d, want %d", url, w.Code, http.StatusNotModified)
+		}
+		if w.Body.Len() > 0 {
+			t.Errorf("%s: got body %q, want none", url, w.Body)
+		}
+		if len(w.Header().Get("ETag")) == 0 {
+			t.Errorf("%s: missing ETag header", url)
+		}
+	}
+}
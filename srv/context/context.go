@@ -7,20 +7,28 @@
 package context
 
 import (
-	"database/sql"
 	"errors"
 	"net/http"
 
+	"github.com/DevMine/api-server/store"
 	"github.com/DevMine/api-server/util/typeutil"
 )
 
 // Context represents a context of a query to the API server that is meant
 // to be used by route handlers functions.
 type Context struct {
-	// DB is an active connection to the database.
-	DB *sql.DB
+	// Store gives access to the data.
+	Store store.Store
 
-	// SinceID corresponds to an ID since which to show results.
+	// RequestID uniquely identifies the request.
+	RequestID string
+
+	// MediaType is the media type of the response, as negotiated with the
+	// client.
+	MediaType string
+
+	// SinceID corresponds to an ID since which to show results. It is set
+	// either from the "since" parameter or from the "cursor" parameter.
 	SinceID uint64
 
 	// PerPage corresponds to the number of results to show per page.
@@ -28,26 +36,43 @@ type Context struct {
 
 	// PageNumber shall be used to paginate results when necessary.
 	PageNumber uint64
+
+	// CursorMode is true when results are paginated using IDs (with the
+	// "cursor" or "since" parameters) rather than page numbers.
+	CursorMode bool
+
+	request *http.Request
+
+	// stream is set when lists are streamed.
+	stream *stream
 }
 
 // NewContext initializes a Context structure.
-func NewContext(db *sql.DB, r *http.Request) (*Context, error) {
-	if db == nil {
-		return nil, errors.New("database session cannot be nil")
+func NewContext(st store.Store, r *http.Request) (*Context, error) {
+	if st == nil {
+		return nil, errors.New("store cannot be nil")
 	}
 
 	if r == nil {
 		return nil, errors.New("http request cannot be nil")
 	}
 
-	err := r.ParseForm()
-	if err != nil {
-		return nil, err
-	}
-	params := r.Form
+	// Pagination parameters are only read from the URL so that the body of
+	// the request is left untouched for the handler.
+	params := r.URL.Query()
 
+	var err error
 	var sinceID uint64
-	sinceID, _ = typeutil.StrToUint(params.Get("since"))
+	var cursorMode bool
+	if cursor := params.Get("cursor"); len(cursor) > 0 {
+		if sinceID, err = decodeCursor(cursor); err != nil {
+			return nil, err
+		}
+		cursorMode = true
+	} else if since := params.Get("since"); len(since) > 0 {
+		sinceID, _ = typeutil.StrToUint(since)
+		cursorMode = true
+	}
 
 	var perPage uint64
 	perPage, _ = typeutil.StrToUint(params.Get("per_page"))
@@ -59,9 +84,31 @@ func NewContext(db *sql.DB, r *http.Request) (*Context, error) {
 
 	var pageNumber uint64
 	pageNumber, _ = typeutil.StrToUint(params.Get("page"))
-	if pageNumber == 0 {
+	if pageNumber == 0 || cursorMode {
 		pageNumber = 1
 	}
 
-	return &Context{DB: db, SinceID: sinceID, PerPage: perPage, PageNumber: pageNumber}, nil
+	return &Context{
+		Store:      st,
+		SinceID:    sinceID,
+		PerPage:    perPage,
+		PageNumber: pageNumber,
+		CursorMode: cursorMode,
+		request:    r,
+	}, nil
+}
+
+// Offset returns the number of results to skip to reach the current page.
+func (c *Context) Offset() uint64 {
+	return (c.PageNumber - 1) * c.PerPage
+}
+
+// ListOptions returns the store list options corresponding to the context.
+// When streaming, the whole list is requested and the store may stream its
+// items.
+func (c *Context) ListOptions() store.ListOptions {
+	if c.stream != nil {
+		return store.ListOptions{SinceID: c.SinceID, Each: c.stream.write}
+	}
+	return store.ListOptions{SinceID: c.SinceID, Offset: c.Offset(), Limit: c.PerPage}
 }
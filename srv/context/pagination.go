@@ -0,0 +1,128 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package context
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/DevMine/api-server/util/httputil"
+	"github.com/DevMine/api-server/util/typeutil"
+)
+
+// cursorPrefix is prepended to the ID before encoding a cursor, which allows
+// changing the cursor format later on.
+const cursorPrefix = "id:"
+
+// ErrInvalidCursor is returned when the "cursor" parameter cannot be decoded.
+var ErrInvalidCursor = httputil.BadRequest(httputil.CodeInvalidParameter, "invalid cursor")
+
+// encodeCursor creates an opaque cursor pointing right after the given ID.
+func encodeCursor(lastID int64) string {
+	return base64.RawURLEncoding.EncodeToString(
+		[]byte(cursorPrefix + typeutil.IntToStr(lastID)))
+}
+
+// decodeCursor decodes a cursor created by encodeCursor and returns the ID
+// since which to show results.
+func decodeCursor(cursor string) (uint64, error) {
+	bs, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(bs), cursorPrefix) {
+		return 0, ErrInvalidCursor
+	}
+
+	id, err := typeutil.StrToUint(strings.TrimPrefix(string(bs), cursorPrefix))
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	return id + 1, nil
+}
+
+// pageURL returns the URL of the current request with its pagination
+// parameters replaced by the given ones.
+func (c *Context) pageURL(params map[string]string) string {
+	r := c.request
+
+	u := url.URL{Scheme: "http", Host: r.Host, Path: r.URL.Path}
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+
+	q := r.URL.Query()
+	for _, p := range []string{"cursor", "since", "page"} {
+		q.Del(p)
+	}
+	q.Set("per_page", typeutil.IntToStr(int64(c.PerPage)))
+	for k, v := range params {
+		q.Set(k, v)
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+// SetPageHeaders sets the pagination headers of a list response: the
+// "X-Total-Count" header, the "Link" header (RFC 5988) and, when there is a
+// next page, the "X-Next-Cursor" header.
+// total is the total number of items in the list, count the number of items
+// in the current page and lastID the ID of the last item of the current page,
+// which may be nil for lists that cannot be paginated using cursors.
+// In cursor mode, there is no "prev" nor "last" link. Since links cannot carry
+// the body of a request, there is no "Link" header in responses to requests
+// other than GET and HEAD. Streamed lists are not paginated and have no
+// pagination headers.
+func (c *Context) SetPageHeaders(w http.ResponseWriter, total int64, count int, lastID *int64) {
+	if c.stream != nil {
+		return
+	}
+
+	var links []string
+	addLink := func(rel string, params map[string]string) {
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, c.pageURL(params), rel))
+	}
+
+	hasNext := uint64(count) == c.PerPage
+	if c.CursorMode {
+		hasNext = hasNext && lastID != nil
+	} else {
+		hasNext = hasNext && c.Offset()+uint64(count) < uint64(total)
+	}
+
+	if hasNext {
+		if c.CursorMode {
+			addLink("next", map[string]string{"cursor": encodeCursor(*lastID)})
+		} else {
+			addLink("next", map[string]string{
+				"page": typeutil.IntToStr(int64(c.PageNumber + 1))})
+		}
+		if lastID != nil {
+			w.Header().Set("X-Next-Cursor", encodeCursor(*lastID))
+		}
+	}
+
+	if !c.CursorMode {
+		if c.PageNumber > 1 {
+			addLink("prev", map[string]string{
+				"page": typeutil.IntToStr(int64(c.PageNumber - 1))})
+		}
+
+		lastPage := (uint64(total) + c.PerPage - 1) / c.PerPage
+		if lastPage == 0 {
+			lastPage = 1
+		}
+		addLink("last", map[string]string{"page": typeutil.IntToStr(int64(lastPage))})
+	}
+
+	addLink("first", nil)
+
+	if c.request.Method == "GET" || c.request.Method == "HEAD" {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+	w.Header().Set("X-Total-Count", typeutil.IntToStr(total))
+}
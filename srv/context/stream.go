@@ -0,0 +1,95 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package context
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+
+	"github.com/DevMine/api-server/util/json"
+)
+
+// flushInterval is the number of items after which a streamed response is
+// flushed.
+const flushInterval = 100
+
+// ErrClientGone is returned when a streamed response is interrupted because
+// the client went away.
+var ErrClientGone = errors.New("client went away")
+
+// stream writes items as newline delimited JSON.
+type stream struct {
+	w    http.ResponseWriter
+	done <-chan struct{}
+
+	// written is the number of items written so far.
+	written int
+}
+
+// write writes an item on its own line and flushes the response every
+// flushInterval items.
+func (s *stream) write(item interface{}) error {
+	select {
+	case <-s.done:
+		return ErrClientGone
+	default:
+	}
+
+	bs := append(json.MarshalPanic(item), '\n')
+	if _, err := s.w.Write(bs); err != nil {
+		return ErrClientGone
+	}
+
+	s.written++
+	if s.written%flushInterval == 0 {
+		s.flush()
+	}
+	return nil
+}
+
+// flush sends the items written so far to the client.
+func (s *stream) flush() {
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// StreamTo makes lists be streamed to w as newline delimited JSON, one item
+// per line, instead of being written as JSON arrays. Streamed lists are not
+// paginated: the page and per_page parameters are ignored.
+func (c *Context) StreamTo(w http.ResponseWriter) {
+	c.stream = &stream{w: w, done: c.request.Context().Done()}
+}
+
+// Streaming reports whether lists are streamed.
+func (c *Context) Streaming() bool {
+	return c.stream != nil
+}
+
+// Streamed reports whether part of the response has already been streamed,
+// in which case it is too late to report an error to the client.
+func (c *Context) Streamed() bool {
+	return c.stream != nil && c.stream.written > 0
+}
+
+// WriteList writes list, which must be a slice, to w: as a JSON array or,
+// when streaming, one item per line following the items already streamed
+// by the store (see ListOptions).
+func (c *Context) WriteList(w http.ResponseWriter, list interface{}) error {
+	if c.stream == nil {
+		w.Write(json.MarshalPanic(list))
+		return nil
+	}
+
+	v := reflect.ValueOf(list)
+	for i := 0; i < v.Len(); i++ {
+		if err := c.stream.write(v.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	c.stream.flush()
+	return nil
+}
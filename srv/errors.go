@@ -0,0 +1,83 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package srv
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+
+	"github.com/golang/glog"
+
+	"github.com/DevMine/api-server/api"
+	"github.com/DevMine/api-server/store"
+	"github.com/DevMine/api-server/util/httputil"
+)
+
+// errorsDocURL points to the documentation about errors.
+const errorsDocURL = api.DocURL + "#client-errors"
+
+// requestIDExp matches request IDs that clients may provide with the
+// X-Request-ID header.
+var requestIDExp = regexp.MustCompile(`^[a-zA-Z0-9\-_\.]{1,64}$`)
+
+// requestID returns the ID of the request: either the one provided by the
+// client, if valid, or a newly generated one.
+func requestID(r *http.Request) string {
+	if id := r.Header.Get("X-Request-ID"); requestIDExp.MatchString(id) {
+		return id
+	}
+
+	bs := make([]byte, 12)
+	if _, err := rand.Read(bs); err != nil {
+		glog.Error(err)
+		return ""
+	}
+	return hex.EncodeToString(bs)
+}
+
+// toHTTPError converts any error returned by a handler into an
+// httputil.Error. Errors that are not meant to be reported to clients are
+// logged and reported as internal server errors.
+func toHTTPError(err error, reqID string) *httputil.Error {
+	switch e := err.(type) {
+	case *httputil.Error:
+		return e
+	}
+
+	switch err {
+	case store.ErrNotFound:
+		return httputil.NotFound("resource not found")
+	case store.ErrUnavailable:
+		return httputil.NewError(http.StatusServiceUnavailable,
+			httputil.CodeServiceUnavailable, "database unavailable, try again later")
+	}
+
+	glog.Errorf("[%s] %v", reqID, err)
+	ise := http.StatusInternalServerError
+	return httputil.NewError(ise, httputil.CodeInternalError, http.StatusText(ise))
+}
+
+// writeError writes err as a JSON error response. The validators set for the
+// response before the error occurred are removed and error responses are never
+// stored by caches.
+func writeError(w http.ResponseWriter, err error, reqID string) {
+	he := toHTTPError(err, reqID)
+
+	w.Header().Del("ETag")
+	w.Header().Del("Last-Modified")
+	w.Header().Set("Cache-Control", "no-store")
+
+	re := httputil.NewResponseError(he.Message)
+	re.Code = he.Code
+	re.DocumentationURL = errorsDocURL
+	re.RequestID = reqID
+
+	if he.Status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "30")
+	}
+	httputil.WriteError(w, re, he.Status)
+}
@@ -0,0 +1,139 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package srv
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DevMine/api-server/config"
+	"github.com/DevMine/api-server/ratelimit"
+	"github.com/DevMine/api-server/util/httputil"
+)
+
+// rateLimiter limits the rate of requests of clients according to the rate
+// limiting configuration. A nil limiter means no limit.
+type rateLimiter struct {
+	// def limits requests on routes without a specific limit.
+	def *ratelimit.Limiter
+
+	// routes holds the per route limiters, keyed by path prefix, and
+	// prefixes lists these prefixes by decreasing length.
+	routes   map[string]*ratelimit.Limiter
+	prefixes []string
+
+	apiKeys    map[string]bool
+	trustProxy bool
+}
+
+// newRateLimiter creates a rateLimiter from the configuration. It returns
+// nil when rate limiting is disabled.
+func newRateLimiter(cfg config.RateLimitConfig) *rateLimiter {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	rl := &rateLimiter{
+		def:        newLimiter(cfg.Default),
+		routes:     make(map[string]*ratelimit.Limiter, len(cfg.Routes)),
+		apiKeys:    make(map[string]bool, len(cfg.APIKeys)),
+		trustProxy: cfg.TrustProxy,
+	}
+	for prefix, limit := range cfg.Routes {
+		prefix = strings.TrimSuffix(prefix, "/")
+		rl.routes[prefix] = newLimiter(limit)
+		rl.prefixes = append(rl.prefixes, prefix)
+	}
+	sort.Slice(rl.prefixes, func(i, j int) bool {
+		return len(rl.prefixes[i]) > len(rl.prefixes[j])
+	})
+	for _, key := range cfg.APIKeys {
+		rl.apiKeys[key] = true
+	}
+
+	return rl
+}
+
+// newLimiter creates a limiter for the given limit, or returns nil if the
+// limit is 0.
+func newLimiter(limit config.RateLimit) *ratelimit.Limiter {
+	if limit.Requests == 0 {
+		return nil
+	}
+
+	burst := limit.Burst
+	if burst == 0 {
+		burst = limit.Requests
+	}
+	return ratelimit.New(ratelimit.Limit{
+		Rate:  float64(limit.Requests) / float64(limit.Period),
+		Burst: burst,
+	})
+}
+
+// limiter returns the limiter to apply to requests on the given path.
+func (rl *rateLimiter) limiter(path string) *ratelimit.Limiter {
+	for _, prefix := range rl.prefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return rl.routes[prefix]
+		}
+	}
+	return rl.def
+}
+
+// clientKey identifies the client which made the request r: by API key when
+// it provides a valid one, by IP address otherwise.
+// When the proxy is trusted, the IP address is the last one of the
+// X-Forwarded-For header, which is appended by the proxy. The previous ones are
+// provided by the client and cannot be trusted.
+func (rl *rateLimiter) clientKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); rl.apiKeys[key] {
+		return "key:" + key
+	}
+
+	if rl.trustProxy {
+		fwd := r.Header["X-Forwarded-For"]
+		if len(fwd) > 0 {
+			addrs := strings.Split(fwd[len(fwd)-1], ",")
+			if addr := strings.TrimSpace(addrs[len(addrs)-1]); len(addr) > 0 {
+				return "ip:" + addr
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// check takes a token from the bucket of the client and sets the rate limit
+// headers of the response. It returns an error when the client exceeded its
+// limit.
+func (rl *rateLimiter) check(w http.ResponseWriter, r *http.Request) error {
+	l := rl.limiter(r.URL.Path)
+	if l == nil {
+		return nil
+	}
+
+	res := l.Allow(rl.clientKey(r))
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(res.Reset.Unix(), 10))
+	if res.Allowed {
+		return nil
+	}
+
+	retryAfter := int64((res.RetryAfter + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+	return httputil.NewError(http.StatusTooManyRequests, httputil.CodeRateLimited,
+		fmt.Sprintf("rate limit exceeded, retry in %d seconds", retryAfter))
+}
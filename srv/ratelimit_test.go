@@ -0,0 +1,53 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package srv
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DevMine/api-server/config"
+)
+
+func TestClientKey(t *testing.T) {
+	tests := []struct {
+		trustProxy bool
+		apiKey     string
+		forwarded  []string
+		want       string
+	}{
+		{false, "", nil, "ip:192.0.2.1"},
+		{false, "", []string{"203.0.113.7"}, "ip:192.0.2.1"},
+		{true, "", nil, "ip:192.0.2.1"},
+		{true, "", []string{"203.0.113.7"}, "ip:203.0.113.7"},
+		{true, "", []string{"10.0.0.1, 203.0.113.7"}, "ip:203.0.113.7"},
+		{true, "", []string{"10.0.0.1", "198.51.100.2,203.0.113.7"}, "ip:203.0.113.7"},
+		{true, "", []string{""}, "ip:192.0.2.1"},
+		{true, "secret", []string{"203.0.113.7"}, "key:secret"},
+		{false, "invalid", nil, "ip:192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		rl := newRateLimiter(config.RateLimitConfig{
+			Enabled:    true,
+			APIKeys:    []string{"secret"},
+			TrustProxy: tt.trustProxy,
+		})
+
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		if len(tt.apiKey) > 0 {
+			r.Header.Set("X-API-Key", tt.apiKey)
+		}
+		for _, fwd := range tt.forwarded {
+			r.Header.Add("X-Forwarded-For", fwd)
+		}
+
+		if got := rl.clientKey(r); got != tt.want {
+			t.Errorf("trust proxy %v, X-Forwarded-For %q: got %q, want %q",
+				tt.trustProxy, tt.forwarded, got, tt.want)
+		}
+	}
+}
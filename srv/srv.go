@@ -7,63 +7,245 @@
 package srv
 
 import (
+	"crypto/subtle"
 	"database/sql"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/gorilla/mux"
 
 	"github.com/DevMine/api-server/api"
+	"github.com/DevMine/api-server/api/admin"
 	"github.com/DevMine/api-server/api/features"
 	repos "github.com/DevMine/api-server/api/repositories"
 	"github.com/DevMine/api-server/api/search"
 	"github.com/DevMine/api-server/api/stats"
 	"github.com/DevMine/api-server/api/users"
 	"github.com/DevMine/api-server/config"
+	"github.com/DevMine/api-server/metrics"
 	"github.com/DevMine/api-server/srv/context"
+	"github.com/DevMine/api-server/store"
 	"github.com/DevMine/api-server/util/httputil"
 )
 
-type handler func(c *context.Context, w http.ResponseWriter, r *http.Request)
+// handler is the prototype of route handlers. When a handler returns an
+// error, it shall not have written anything to w.
+type handler func(c *context.Context, w http.ResponseWriter, r *http.Request) error
+
+// statusRecorder is an http.ResponseWriter which records the status code of
+// the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// code returns the status code of the response. A response whose header is
+// never written explicitly is sent with 200 OK.
+func (sr *statusRecorder) code() int {
+	if sr.status == 0 {
+		return http.StatusOK
+	}
+	return sr.status
+}
+
+// WriteHeader implements the http.ResponseWriter interface.
+func (sr *statusRecorder) WriteHeader(code int) {
+	if sr.status == 0 {
+		sr.status = code
+	}
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements the http.ResponseWriter interface.
+func (sr *statusRecorder) Write(bs []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(bs)
+}
+
+// Flush implements the http.Flusher interface.
+func (sr *statusRecorder) Flush() {
+	if f, ok := sr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// handlerConfig holds the parameters shared by all route handlers.
+type handlerConfig struct {
+	// store gives access to the data.
+	store store.Store
+
+	// cors specifies whether to enable Cross Origin Resource Sharing.
+	cors bool
+
+	// cacheControl is the Cache-Control header value of cacheable routes.
+	cacheControl string
+
+	// compress specifies whether to compress responses larger than
+	// compressMinSize bytes.
+	compress        bool
+	compressMinSize int
+
+	// limiter limits the rate of requests of clients. It is nil when rate
+	// limiting is disabled.
+	limiter *rateLimiter
+}
+
+// newHandlerConfig creates a handlerConfig from the configuration.
+func newHandlerConfig(st store.Store, cfg *config.Config) *handlerConfig {
+	hc := &handlerConfig{
+		store:           st,
+		cors:            cfg.Server.EnableCors,
+		cacheControl:    cfg.Cache.HTTPCacheControl,
+		compress:        cfg.Compression.Enabled,
+		compressMinSize: cfg.Compression.MinSize,
+		limiter:         newRateLimiter(cfg.RateLimit),
+	}
+	if len(hc.cacheControl) == 0 {
+		hc.cacheControl = defaultCacheControl
+	}
+	return hc
+}
+
+// corsMaxAge is the time, in seconds, during which clients may cache the
+// response to a preflight request.
+const corsMaxAge = 600
+
+// defaultMediaTypes are the media types served by routes which do not
+// specify any.
+var defaultMediaTypes = []string{"application/json"}
+
+// listMediaTypes are the media types served by routes returning lists, which
+// can be streamed as newline delimited JSON.
+var listMediaTypes = []string{"application/json", httputil.MediaTypeNDJSON}
+
+// routeOptions alter the way a route is handled.
+type routeOptions struct {
+	// cacheable routes are routes that only serve data which changes when
+	// the cache is reloaded; conditional requests are supported on these
+	// routes.
+	cacheable bool
+
+	// methods are the HTTP methods the route answers to.
+	methods []string
+
+	// mediaTypes are the media types the route is able to produce, by order
+	// of preference. The one chosen according to the Accept header of the
+	// request is given to the handler in the context. Defaults to
+	// defaultMediaTypes.
+	mediaTypes []string
+}
+
+// makeHandler creates the handler function prototype.
+// The route name is used to label the metrics.
+func makeHandler(hc *handlerConfig, route string, h handler, opts routeOptions) http.HandlerFunc {
+	mediaTypes := opts.mediaTypes
+	if len(mediaTypes) == 0 {
+		mediaTypes = defaultMediaTypes
+	}
+
+	return func(rw http.ResponseWriter, r *http.Request) {
+		tic := time.Now()
+		rec := &statusRecorder{ResponseWriter: rw}
+
+		var w http.ResponseWriter = rec
+		closeWriter := func() {}
+		if hc.compress {
+			w, closeWriter = newCompressWriter(rec, r, hc.compressMinSize)
+		}
+
+		reqID := requestID(r)
+		w.Header().Set("X-Request-ID", reqID)
 
-// makeHandler creates the handler function prototype
-func makeHandler(db *sql.DB, h handler, cors bool) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				glog.Error(err)
-				ise := http.StatusInternalServerError
-				he := httputil.NewResponseError(http.StatusText(ise))
-				http.Error(w, he.JSON(), ise)
+				metrics.ObservePanic(route)
+				writeError(w, fmt.Errorf("panic: %v", err), reqID)
 			}
+			closeWriter()
+			metrics.ObserveRequest(route, r.Method, rec.code(), time.Since(tic))
 		}()
 
-		c, err := context.NewContext(db, r)
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(opts.methods, ", "))
+
+		// enable Cross Origin Resource Sharing
+		if hc.cors {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Headers",
+				"Origin, Accept, Content-Type, X-Requested-With, X-CSRF-Token, X-API-Key, "+
+					"X-Request-ID, If-None-Match, If-Modified-Since")
+			w.Header().Set("Access-Control-Expose-Headers",
+				"Link, X-Total-Count, X-Next-Cursor, X-Request-ID, ETag, Last-Modified, "+
+					"X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, Retry-After")
+
+			// answer preflight requests
+			if r.Method == "OPTIONS" {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(corsMaxAge))
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+
+		if hc.limiter != nil {
+			if err := hc.limiter.check(w, r); err != nil {
+				writeError(w, err, reqID)
+				return
+			}
+		}
+
+		c, err := context.NewContext(hc.store, r)
 		if err != nil {
-			panic(err)
+			writeError(w, err, reqID)
+			return
 		}
+		c.RequestID = reqID
 
-		// we only serve JSON
-		w.Header().Set("Content-Type", "application/json")
+		mediaType, ok := httputil.NegotiateMediaType(r.Header.Get("Accept"), mediaTypes)
+		if !ok {
+			writeError(w, httputil.NewError(http.StatusNotAcceptable, httputil.CodeNotAcceptable,
+				"acceptable media types: "+strings.Join(mediaTypes, ", ")), reqID)
+			return
+		}
+		c.MediaType = mediaType
+		w.Header().Set("Content-Type", mediaType)
+		if len(mediaTypes) > 1 {
+			w.Header().Add("Vary", "Accept")
+		}
 
-		// the server only accepts GET requests
-		w.Header().Set("Access-Control-Allow-Methods", "GET")
+		// the handler is run even when the response is not modified, so
+		// that errors take precedence over 304 Not Modified
+		cacheable := opts.cacheable && (r.Method == "GET" || r.Method == "HEAD")
+		if cacheable && checkCached(w, r, mediaType, hc.cacheControl) {
+			w = &notModifiedWriter{ResponseWriter: w}
+		}
 
-		// enable Cross Origin Resource Sharing
-		if cors {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Headers",
-				"Origin, Accept, Content-Type, X-Requested-With, X-CSRF-Token")
+		if mediaType == httputil.MediaTypeNDJSON {
+			c.StreamTo(w)
 		}
 
 		requestURI, err := url.QueryUnescape(r.RequestURI)
 		if err != nil {
 			requestURI = r.RequestURI
 		}
-		glog.Infof("%s %s from %s", r.Method, requestURI, r.RemoteAddr)
-		h(c, w, r)
+		glog.Infof("[%s] %s %s from %s", reqID, r.Method, requestURI, r.RemoteAddr)
+		if err := h(c, w, r); err != nil {
+			switch {
+			case err == context.ErrClientGone:
+				glog.Infof("[%s] %v", reqID, err)
+			case c.Streamed():
+				// the status code has already been sent
+				glog.Errorf("[%s] %v", reqID, err)
+			default:
+				writeError(w, err, reqID)
+			}
+		}
 	}
 }
 
@@ -73,61 +255,186 @@ func notFoundHandler() http.Handler {
 		msg := fmt.Sprintf(
 			"Mmmh... It looks like you're lost in the void... Here, read this scroll: %s",
 			api.DocURL)
-		he := httputil.NewResponseError(msg)
-		http.Error(w, he.JSON(), http.StatusNotFound)
+		reqID := requestID(r)
+		w.Header().Set("X-Request-ID", reqID)
+		writeError(w, httputil.NotFound(msg), reqID)
 	})
 }
 
-// SetupRouter creates API routes.
-// The cors parameter is used to specify whether to enable
-// Cross Origin Resource Sharing or not.
-func SetupRouter(db *sql.DB, cors bool) *mux.Router {
+// requireAdmin wraps a handler so that it is only run when the request
+// provides the admin token, in the form "Authorization: token <admin_token>".
+func requireAdmin(token string, h handler) handler {
+	return func(c *context.Context, w http.ResponseWriter, r *http.Request) error {
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "token ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			return httputil.NewError(http.StatusUnauthorized,
+				httputil.CodeUnauthorized, "invalid admin token")
+		}
+		return h(c, w, r)
+	}
+}
+
+// SetupRouter creates API routes according to the server configuration.
+// Handlers access the data through the given store.
+func SetupRouter(st store.Store, cfg *config.Config) *mux.Router {
 	r := mux.NewRouter()
+	hc := newHandlerConfig(st, cfg)
+
+	// register registers a route answering to the given methods, to HEAD
+	// requests when GET is among them and, when CORS is enabled, to preflight
+	// requests. The path is also used as route name in the metrics.
+	register := func(path string, h handler, opts routeOptions) {
+		methods := opts.methods[:len(opts.methods):len(opts.methods)]
+		for _, m := range opts.methods {
+			if m == "GET" {
+				methods = append(methods, "HEAD")
+			}
+		}
+		if hc.cors {
+			methods = append(methods, "OPTIONS")
+		}
+		r.HandleFunc(path, makeHandler(hc, path, h, opts)).Methods(methods...)
+	}
+
+	// handle registers a route.
+	handle := func(path string, h handler, methods ...string) {
+		register(path, h, routeOptions{methods: methods})
+	}
+
+	// handleCached registers a cacheable route.
+	handleCached := func(path string, h handler, methods ...string) {
+		register(path, h, routeOptions{methods: methods, cacheable: true})
+	}
+
+	// handleList registers a route returning a list, which can be streamed.
+	handleList := func(path string, h handler, cacheable bool, methods ...string) {
+		register(path, h, routeOptions{methods: methods, cacheable: cacheable, mediaTypes: listMediaTypes})
+	}
 
 	// 404 Not Found routes
 	r.NotFoundHandler = notFoundHandler()
 
 	// default route
-	r.HandleFunc("/",
-		makeHandler(db, api.Index, cors)).Methods("GET")
+	handle("/", api.Index, "GET")
 
 	// features
-	r.HandleFunc("/features",
-		makeHandler(db, features.Index, cors)).Methods("GET")
-	r.HandleFunc("/features/by_category/{category:[a-zA-Z]+}",
-		makeHandler(db, features.ByCategory, cors)).Methods("GET")
-	r.HandleFunc("/features/{name:[a-zA-Z0-9_]+}/scores",
-		makeHandler(db, features.ShowScores, cors)).Methods("GET")
+	handleList("/features", features.Index, true, "GET")
+	handleList("/features/by_category/{category:[a-zA-Z]+}", features.ByCategory, true, "GET")
+	handleList("/features/{name:[a-zA-Z0-9_]+}/scores", features.ShowScores, false, "GET")
 
 	// repositories
-	r.HandleFunc("/repositories",
-		makeHandler(db, repos.Index, cors)).Methods("GET")
-	r.HandleFunc("/repositories/{name:[a-zA-Z0-9\\-_\\.]+}",
-		makeHandler(db, repos.Show, cors)).Methods("GET")
+	handleList("/repositories", repos.Index, false, "GET")
+	handleList("/repositories/{name:[a-zA-Z0-9\\-_\\.]+}", repos.Show, false, "GET")
 
 	// search
-	r.HandleFunc("/search/{query}",
-		makeHandler(db, search.Query, cors)).Methods("GET")
+	searcher := search.New(cfg.Search)
+	handleList("/search", searcher.Search, true, "GET", "POST")
+	handleList("/search/{query}", searcher.Query, true, "GET")
+	handleList("/teams", searcher.Teams, true, "GET", "POST")
 
 	// stats
-	r.HandleFunc("/stats",
-		makeHandler(db, stats.Index, cors)).Methods("GET")
+	handleCached("/stats", stats.Index, "GET")
+	handle("/stats/cache", stats.Cache, "GET")
 
 	// users
-	r.HandleFunc("/users",
-		makeHandler(db, users.Index, cors)).Methods("GET")
-	r.HandleFunc("/users/{username:[a-zA-Z0-9-_\\.]+}",
-		makeHandler(db, users.Show, cors)).Methods("GET")
-	r.HandleFunc("/users/{username:[a-zA-Z0-9-_\\.]+}/commits",
-		makeHandler(db, users.ShowCommits, cors)).Methods("GET")
-	r.HandleFunc("/users/{username:[a-zA-Z0-9-_\\.]+}/repositories",
-		makeHandler(db, users.ShowRepositories, cors)).Methods("GET")
-	r.HandleFunc("/users/{username:[a-zA-Z0-9-_\\.]+}/scores",
-		makeHandler(db, users.ShowScores, cors)).Methods("GET")
+	similarity := users.NewSimilarity(cfg.Search)
+	handleList("/users", users.Index, false, "GET")
+	handle("/users/{username:[a-zA-Z0-9-_\\.]+}", users.Show, "GET")
+	handleList("/users/{username:[a-zA-Z0-9-_\\.]+}/commits", users.ShowCommits, false, "GET")
+	handleList("/users/{username:[a-zA-Z0-9-_\\.]+}/repositories", users.ShowRepositories, false, "GET")
+	handleCached("/users/{username:[a-zA-Z0-9-_\\.]+}/scores", users.ShowScores, "GET")
+	handleList("/users/{username:[a-zA-Z0-9-_\\.]+}/similar", similarity.ShowSimilar, true, "GET")
+	handleCached("/users/{username:[a-zA-Z0-9-_\\.]+}/rank", searcher.UserRank, "GET")
+
+	// admin
+	if len(cfg.Server.AdminToken) > 0 {
+		handle("/admin/cache/reload",
+			requireAdmin(cfg.Server.AdminToken, admin.ReloadCache), "POST")
+	}
+
+	// metrics, unless served on a separate listener
+	if cfg.Metrics.Enabled && cfg.Metrics.Port == 0 {
+		r.Handle("/metrics", metrics.Handler()).Methods("GET")
+	}
 
 	return r
 }
 
+// NewServer creates an HTTP server that listens on the address specified in
+// the configuration and serves requests using the given handler.
+// When TLS is enabled in the configuration, the server has a TLS
+// configuration and must be started with this package's ListenAndServe.
+func NewServer(cfg config.ServerConfig, h http.Handler) (*http.Server, error) {
+	tlsCfg, err := cfg.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Server{
+		Addr:         fmt.Sprintf("%s:%d", cfg.HostName, cfg.Port),
+		Handler:      h,
+		TLSConfig:    tlsCfg,
+		ReadTimeout:  time.Duration(cfg.ReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(cfg.WriteTimeout) * time.Second,
+		IdleTimeout:  time.Duration(cfg.IdleTimeout) * time.Second,
+	}, nil
+}
+
+// NewRedirectServer creates an HTTP server that listens on the HTTP redirect
+// port specified in the configuration and permanently redirects all requests
+// to the HTTPS server.
+func NewRedirectServer(cfg config.ServerConfig) *http.Server {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if cfg.Port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(cfg.Port))
+		} else if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+			// IPv6 literals must be bracketed, even without port
+			host = "[" + host + "]"
+		}
+
+		u := url.URL{
+			Scheme:   "https",
+			Host:     host,
+			Path:     r.URL.Path,
+			RawQuery: r.URL.RawQuery,
+		}
+		http.Redirect(w, r, u.String(), http.StatusPermanentRedirect)
+	})
+
+	return &http.Server{
+		Addr:        fmt.Sprintf("%s:%d", cfg.HostName, cfg.HTTPRedirectPort),
+		Handler:     h,
+		ReadTimeout: time.Duration(cfg.ReadTimeout) * time.Second,
+		IdleTimeout: time.Duration(cfg.IdleTimeout) * time.Second,
+	}
+}
+
+// ListenAndServe starts the server, serving HTTPS if the server has a TLS
+// configuration and plain HTTP otherwise.
+func ListenAndServe(server *http.Server) error {
+	if server.TLSConfig != nil {
+		// the certificates are already part of the TLS configuration
+		return server.ListenAndServeTLS("", "")
+	}
+	return server.ListenAndServe()
+}
+
+// NewMetricsServer creates an HTTP server serving the metrics on the address
+// specified in the configuration.
+func NewMetricsServer(cfg config.MetricsConfig) *http.Server {
+	r := http.NewServeMux()
+	r.Handle("/metrics", metrics.Handler())
+
+	return &http.Server{
+		Addr:    fmt.Sprintf("%s:%d", cfg.HostName, cfg.Port),
+		Handler: r,
+	}
+}
+
 // OpenDBSession creates a session to the database.
 func OpenDBSession(cfg config.DatabaseConfig) (*sql.DB, error) {
 	dbURL := fmt.Sprintf(
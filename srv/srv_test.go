@@ -0,0 +1,401 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package srv
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/DevMine/api-server/cache"
+	"github.com/DevMine/api-server/config"
+	"github.com/DevMine/api-server/metrics"
+	"github.com/DevMine/api-server/model"
+	"github.com/DevMine/api-server/srv/context"
+	"github.com/DevMine/api-server/store"
+)
+
+func int64Ptr(n int64) *int64    { return &n }
+func stringPtr(s string) *string { return &s }
+
+// newTestStore returns a store holding n users, named user1 to userN.
+func newTestStore(n int) *store.Memory {
+	st := store.NewMemory()
+	for i := 1; i <= n; i++ {
+		st.Users = append(st.Users, model.User{
+			ID:       int64Ptr(int64(i)),
+			Username: stringPtr("user" + strconv.Itoa(i)),
+		})
+	}
+	st.Features = []model.Feature{
+		{ID: int64Ptr(1), Name: stringPtr("followers_count"), Category: stringPtr("other"), DefaultWeight: int64Ptr(1)},
+		{ID: int64Ptr(2), Name: stringPtr("stars_avg"), Category: stringPtr("other"), DefaultWeight: int64Ptr(1)},
+	}
+	return st
+}
+
+// loadTestCache loads a cache snapshot with the users and features of the
+// given store, user i having the scores i and n - i.
+func loadTestCache(t *testing.T, st *store.Memory) {
+	n := len(st.Users)
+	scores := make([][]float64, n)
+	for i := range scores {
+		scores[i] = []float64{float64(i + 1), float64(n - i - 1)}
+	}
+
+	err := cache.LoadFixture(cache.Fixture{
+		Stats:    model.Stats{UsersCount: int64Ptr(int64(n))},
+		Features: st.Features,
+		Users:    st.Users,
+		Scores:   scores,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestRouter returns a router over a store of n users, with the cache
+// loaded from the same users.
+func newTestRouter(t *testing.T, n int, cfg *config.Config) http.Handler {
+	st := newTestStore(n)
+	loadTestCache(t, st)
+	return SetupRouter(st, cfg)
+}
+
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestRouterStatus(t *testing.T) {
+	r := newTestRouter(t, 10, &config.Config{})
+
+	tests := []struct {
+		method, url string
+		status      int
+	}{
+		{"GET", "/", http.StatusOK},
+		{"GET", "/users", http.StatusOK},
+		{"GET", "/users/user3", http.StatusOK},
+		{"GET", "/users/USER3", http.StatusOK},
+		{"GET", "/users/nobody", http.StatusNotFound},
+		{"GET", "/users/nobody/commits", http.StatusNotFound},
+		{"GET", "/users/user3/scores", http.StatusOK},
+		{"GET", "/users/nobody/scores", http.StatusNotFound},
+		{"GET", "/users/user3/similar", http.StatusOK},
+		{"GET", "/users/user3/rank", http.StatusOK},
+		{"GET", "/features", http.StatusOK},
+		{"GET", "/features/nope/scores", http.StatusNotFound},
+		{"GET", "/stats", http.StatusOK},
+		{"GET", `/search/{"stars_avg":2}`, http.StatusOK},
+		{"GET", `/search/{"nope":2}`, http.StatusBadRequest},
+		{"GET", "/nothing", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, strings.ReplaceAll(tt.url, `"`, "%22"), nil)
+		if w := serve(r, req); w.Code != tt.status {
+			t.Errorf("%s %s: got status %d, want %d: %s", tt.method, tt.url, w.Code, tt.status, w.Body)
+		}
+	}
+}
+
+func TestRouterPagination(t *testing.T) {
+	r := newTestRouter(t, 10, &config.Config{})
+
+	tests := []struct {
+		url   string
+		total string
+		links []string
+	}{
+		{
+			url:   "/users?per_page=3",
+			total: "10",
+			links: []string{
+				`<http://example.com/users?page=2&per_page=3>; rel="next"`,
+				`<http://example.com/users?page=4&per_page=3>; rel="last"`,
+				`<http://example.com/users?per_page=3>; rel="first"`,
+			},
+		},
+		{
+			url:   "/users?per_page=3&page=4",
+			total: "10",
+			links: []string{
+				`<http://example.com/users?page=3&per_page=3>; rel="prev"`,
+				`<http://example.com/users?page=4&per_page=3>; rel="last"`,
+				`<http://example.com/users?per_page=3>; rel="first"`,
+			},
+		},
+		{
+			url:   "/search?per_page=4&page=2&q={}",
+			total: "10",
+			links: []string{
+				`<http://example.com/search?page=3&per_page=4&q=%7B%7D>; rel="next"`,
+				`<http://example.com/search?page=1&per_page=4&q=%7B%7D>; rel="prev"`,
+				`<http://example.com/search?page=3&per_page=4&q=%7B%7D>; rel="last"`,
+				`<http://example.com/search?per_page=4&q=%7B%7D>; rel="first"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		w := serve(r, httptest.NewRequest("GET", strings.NewReplacer("{", "%7B", "}", "%7D").Replace(tt.url), nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: got status %d, want %d", tt.url, w.Code, http.StatusOK)
+			continue
+		}
+		if total := w.Header().Get("X-Total-Count"); total != tt.total {
+			t.Errorf("%s: got X-Total-Count %q, want %q", tt.url, total, tt.total)
+		}
+		links := strings.Split(w.Header().Get("Link"), ", ")
+		if strings.Join(links, "\n") != strings.Join(tt.links, "\n") {
+			t.Errorf("%s: got links\n%s\nwant\n%s", tt.url, strings.Join(links, "\n"), strings.Join(tt.links, "\n"))
+		}
+	}
+}
+
+func TestRouterCursor(t *testing.T) {
+	r := newTestRouter(t, 10, &config.Config{})
+
+	w := serve(r, httptest.NewRequest("GET", "/users?per_page=4", nil))
+	cursor := w.Header().Get("X-Next-Cursor")
+	if len(cursor) == 0 {
+		t.Fatal("missing X-Next-Cursor header")
+	}
+
+	w = serve(r, httptest.NewRequest("GET", "/users?per_page=4&cursor="+cursor, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+	if !strings.HasPrefix(w.Body.String(), `[{"id":5,`) {
+		t.Errorf("got %s, want users since ID 5", w.Body)
+	}
+
+	w = serve(r, httptest.NewRequest("GET", "/users?cursor=invalid", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid cursor: got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestRouterSearchPost(t *testing.T) {
+	r := newTestRouter(t, 10, &config.Config{})
+
+	for _, contentType := range []string{"application/json", "application/x-www-form-urlencoded"} {
+		req := httptest.NewRequest("POST", "/search?per_page=2&page=2", strings.NewReader(`{"weights":{"stars_avg":1}}`))
+		req.Header.Set("Content-Type", contentType)
+
+		w := serve(r, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: got status %d, want %d: %s", contentType, w.Code, http.StatusOK, w.Body)
+			continue
+		}
+		if total := w.Header().Get("X-Total-Count"); total != "10" {
+			t.Errorf("%s: got X-Total-Count %q, want %q", contentType, total, "10")
+		}
+		if link := w.Header().Get("Link"); len(link) > 0 {
+			t.Errorf("%s: got Link header %q, want none", contentType, link)
+		}
+		if n := strings.Count(w.Body.String(), `"username"`); n != 2 {
+			t.Errorf("%s: got %d results, want 2", contentType, n)
+		}
+	}
+}
+
+func TestRouterSearchTooLarge(t *testing.T) {
+	r := newTestRouter(t, 3, &config.Config{})
+
+	body := `{"weights":{"stars_avg":1}` + strings.Repeat(" ", 1<<20) + "}"
+	w := serve(r, httptest.NewRequest("POST", "/search", strings.NewReader(body)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+	if !strings.Contains(w.Body.String(), `"request_too_large"`) {
+		t.Errorf("got %s, want a request_too_large error", w.Body)
+	}
+}
+
+func TestRouterTeams(t *testing.T) {
+	r := newTestRouter(t, 10, &config.Config{})
+
+	tests := []struct {
+		query string
+		body  string
+	}{
+		// user10 has the highest followers_count and user1 the highest
+		// stars_avg
+		{`{"requirements":{"followers_count":10,"stars_avg":9}}`, `"username":"user10"`},
+		{`{"requirements":{"followers_count":100}}`, "[]"},
+		// sizes of 0 stand for their default values
+		{`{"requirements":{"followers_count":10},"max_size":0,"count":0}`, `"username":"user10"`},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/teams", strings.NewReader(tt.query))
+		w := serve(r, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: got status %d, want %d: %s", tt.query, w.Code, http.StatusOK, w.Body)
+			continue
+		}
+		if !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("%s: got %s, want %s", tt.query, w.Body, tt.body)
+		}
+	}
+
+	invalid := []string{
+		`{"requirements":{"followers_count":10},"max_size":-1}`,
+		`{"requirements":{"followers_count":10},"max_size":11}`,
+		`{"requirements":{"followers_count":10},"count":-1}`,
+		`{"requirements":{"followers_count":10},"count":21}`,
+	}
+	for _, query := range invalid {
+		req := httptest.NewRequest("POST", "/teams", strings.NewReader(query))
+		if w := serve(r, req); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestRouterSimilar(t *testing.T) {
+	r := newTestRouter(t, 30, &config.Config{Search: config.SearchConfig{MaxSimilar: 20}})
+
+	tests := []struct {
+		url    string
+		status int
+		count  int
+	}{
+		{"/users/user1/similar", http.StatusOK, 10},
+		{"/users/user1/similar?k=3", http.StatusOK, 3},
+		{"/users/user1/similar?k=20", http.StatusOK, 20},
+		{"/users/user1/similar?k=0", http.StatusBadRequest, 0},
+		{"/users/user1/similar?k=21", http.StatusBadRequest, 0},
+		{"/users/user1/similar?k=many", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		w := serve(r, httptest.NewRequest("GET", tt.url, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: got status %d, want %d", tt.url, w.Code, tt.status)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		if n := strings.Count(w.Body.String(), `"username"`); n != tt.count {
+			t.Errorf("%s: got %d users, want %d", tt.url, n, tt.count)
+		}
+		if total := w.Header().Get("X-Total-Count"); total != strconv.Itoa(tt.count) {
+			t.Errorf("%s: got X-Total-Count %q, want %d", tt.url, total, tt.count)
+		}
+	}
+}
+
+func TestRouterSearchStream(t *testing.T) {
+	r := newTestRouter(t, 10, &config.Config{Search: config.SearchConfig{MaxResults: 4}})
+
+	queries := []string{
+		`{}`,
+		`{"mode":"skyline","skyline":{"features":["followers_count"]}}`,
+	}
+
+	for _, q := range queries {
+		target := "/search?q=" + url.QueryEscape(q)
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("Accept", "application/x-ndjson")
+
+		w := serve(r, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: got status %d, want %d: %s", target, w.Code, http.StatusOK, w.Body)
+			continue
+		}
+		if n := strings.Count(w.Body.String(), "\n"); n != 4 {
+			t.Errorf("%s: got %d streamed results, want 4", target, n)
+		}
+	}
+}
+
+func TestRedirectServer(t *testing.T) {
+	tests := []struct {
+		port int
+		host string
+		want string
+	}{
+		{443, "example.com", "https://example.com/users?page=2"},
+		{443, "example.com:80", "https://example.com/users?page=2"},
+		{8443, "example.com:80", "https://example.com:8443/users?page=2"},
+		{443, "[::1]", "https://[::1]/users?page=2"},
+		{443, "[::1]:80", "https://[::1]/users?page=2"},
+		{8443, "[::1]:80", "https://[::1]:8443/users?page=2"},
+	}
+
+	for _, tt := range tests {
+		s := NewRedirectServer(config.ServerConfig{Port: tt.port})
+
+		req := httptest.NewRequest("GET", "http://"+tt.host+"/users?page=2", nil)
+		w := serve(s.Handler, req)
+		if w.Code != http.StatusPermanentRedirect {
+			t.Errorf("%s: got status %d, want %d", tt.host, w.Code, http.StatusPermanentRedirect)
+		}
+		if loc := w.Header().Get("Location"); loc != tt.want {
+			t.Errorf("%s on port %d: got location %q, want %q", tt.host, tt.port, loc, tt.want)
+		}
+	}
+}
+
+func TestRequestMetricsEmptyResponse(t *testing.T) {
+	hc := newHandlerConfig(newTestStore(1), &config.Config{})
+	empty := func(c *context.Context, w http.ResponseWriter, r *http.Request) error {
+		return nil
+	}
+	h := makeHandler(hc, "/test/empty", empty, routeOptions{methods: []string{"GET"}})
+
+	if w := serve(h, httptest.NewRequest("GET", "/test/empty", nil)); w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+
+	w := serve(metrics.Handler(), httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if strings.Contains(line, `route="/test/empty"`) && strings.Contains(line, "requests_total") {
+			if !strings.Contains(line, `code="200"`) {
+				t.Errorf("got metric %s, want code 200", line)
+			}
+			return
+		}
+	}
+	t.Error("request not counted")
+}
+
+func TestRouterScoresWithoutCachedScores(t *testing.T) {
+	st := newTestStore(3)
+	loadTestCache(t, st)
+
+	// user4 exists but has no score, hence is not part of the cache
+	st.Users = append(st.Users, model.User{ID: int64Ptr(4), Username: stringPtr("user4")})
+	r := SetupRouter(st, &config.Config{})
+
+	tests := []struct {
+		url    string
+		status int
+		body   string
+	}{
+		{"/users/user1/scores", http.StatusOK, `"followers_count"`},
+		{"/users/user4/scores", http.StatusOK, "{}"},
+		{"/users/nobody/scores", http.StatusNotFound, `"not_found"`},
+	}
+
+	for _, tt := range tests {
+		w := serve(r, httptest.NewRequest("GET", tt.url, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: got status %d, want %d", tt.url, w.Code, tt.status)
+			continue
+		}
+		if body := strings.TrimSpace(w.Body.String()); !strings.Contains(body, tt.body) {
+			t.Errorf("%s: got %s, want %s", tt.url, body, tt.body)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/DevMine/api-server/model"
+)
+
+// Memory is a Store that serves data held in memory. It is mostly useful to
+// run the server with fixtures, for instance in unit tests. Lists are always
+// returned, regardless of the Each list option.
+// The exported fields shall be filled before the store is used and not be
+// modified afterwards. Items are expected to have non nil IDs.
+type Memory struct {
+	// Users are the users, with their GitHub information.
+	Users []model.User
+
+	// Commits are the commits. The Author of each commit is used to match
+	// commits with users.
+	Commits []model.Commit
+
+	// Repositories are the repositories, with their GitHub information.
+	Repositories []model.Repository
+
+	// UsersRepositories maps user IDs to the IDs of their repositories.
+	UsersRepositories map[int64][]int64
+
+	// Features are the features.
+	Features []model.Feature
+
+	// Scores are the scores of the users for each feature.
+	Scores []model.Score
+}
+
+// NewMemory creates an empty in-memory Store.
+func NewMemory() *Memory {
+	return &Memory{UsersRepositories: make(map[int64][]int64)}
+}
+
+// equalFold reports whether the string pointed to by p is equal to s under
+// Unicode case-folding.
+func equalFold(p *string, s string) bool {
+	return p != nil && strings.EqualFold(*p, s)
+}
+
+// since reports whether id is greater or equal than opts.SinceID.
+func (opts ListOptions) since(id *int64) bool {
+	return id != nil && *id >= 0 && uint64(*id) >= opts.SinceID
+}
+
+// bounds returns the bounds of the slice of a list of n items, already
+// filtered with SinceID, to return according to the options.
+func (opts ListOptions) bounds(n int) (lo, hi int) {
+	if opts.Offset >= uint64(n) {
+		return n, n
+	}
+	lo = int(opts.Offset)
+
+	hi = n
+	if opts.Limit > 0 && uint64(hi-lo) > opts.Limit {
+		hi = lo + int(opts.Limit)
+	}
+	return lo, hi
+}
+
+func (m *Memory) userByUsername(username string) *model.User {
+	for i := range m.Users {
+		if equalFold(m.Users[i].Username, username) {
+			return &m.Users[i]
+		}
+	}
+	return nil
+}
+
+func (m *Memory) userByID(id *int64) *model.User {
+	if id == nil {
+		return nil
+	}
+	for i := range m.Users {
+		if u := m.Users[i]; u.ID != nil && *u.ID == *id {
+			return &model.User{ID: u.ID, Username: u.Username, Name: u.Name, Email: u.Email}
+		}
+	}
+	return nil
+}
+
+func (m *Memory) featureByName(name string) *model.Feature {
+	for i := range m.Features {
+		if equalFold(m.Features[i].Name, name) {
+			return &m.Features[i]
+		}
+	}
+	return nil
+}
+
+// ListUsers implements the Store interface.
+func (m *Memory) ListUsers(opts ListOptions) ([]model.User, int64, error) {
+	users := make([]model.User, 0)
+	for _, u := range m.Users {
+		if opts.since(u.ID) {
+			users = append(users, u)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return *users[i].ID < *users[j].ID })
+
+	lo, hi := opts.bounds(len(users))
+	return users[lo:hi], int64(len(m.Users)), nil
+}
+
+// UserByUsername implements the Store interface.
+func (m *Memory) UserByUsername(username string) (*model.User, error) {
+	u := m.userByUsername(username)
+	if u == nil {
+		return nil, ErrNotFound
+	}
+	return u, nil
+}
+
+// CommitsByAuthor implements the Store interface.
+func (m *Memory) CommitsByAuthor(username string, opts ListOptions) ([]model.Commit, int64, error) {
+	if m.userByUsername(username) == nil {
+		return nil, 0, ErrNotFound
+	}
+
+	var total int64
+	commits := make([]model.Commit, 0)
+	for _, c := range m.Commits {
+		if c.Author == nil || !equalFold(c.Author.Username, username) {
+			continue
+		}
+		total++
+		if opts.since(c.ID) {
+			commits = append(commits, c)
+		}
+	}
+	sort.Slice(commits, func(i, j int) bool { return *commits[i].ID < *commits[j].ID })
+
+	lo, hi := opts.bounds(len(commits))
+	return commits[lo:hi], total, nil
+}
+
+// RepositoriesByUser implements the Store interface.
+func (m *Memory) RepositoriesByUser(username string, opts ListOptions) ([]model.Repository, error) {
+	u := m.userByUsername(username)
+	if u == nil || u.ID == nil {
+		return nil, ErrNotFound
+	}
+
+	repositories := make([]model.Repository, 0)
+
+	ids := make(map[int64]struct{})
+	for _, id := range m.UsersRepositories[*u.ID] {
+		ids[id] = struct{}{}
+	}
+
+	for _, r := range m.Repositories {
+		if _, ok := ids[*r.ID]; ok {
+			repositories = append(repositories, r)
+		}
+	}
+	sort.Slice(repositories, func(i, j int) bool {
+		return *repositories[i].ID < *repositories[j].ID
+	})
+
+	lo, hi := opts.bounds(len(repositories))
+	return repositories[lo:hi], nil
+}
+
+// ListRepositories implements the Store interface.
+func (m *Memory) ListRepositories(opts ListOptions) ([]model.Repository, int64, error) {
+	repositories := m.filterRepositories(opts, func(model.Repository) bool { return true })
+	return repositories, int64(len(m.Repositories)), nil
+}
+
+// RepositoriesByName implements the Store interface.
+func (m *Memory) RepositoriesByName(name string, opts ListOptions) ([]model.Repository, error) {
+	return m.filterRepositories(opts, func(r model.Repository) bool {
+		return equalFold(r.Name, name)
+	}), nil
+}
+
+func (m *Memory) filterRepositories(opts ListOptions, keep func(model.Repository) bool) []model.Repository {
+	repositories := make([]model.Repository, 0)
+	for _, r := range m.Repositories {
+		if opts.since(r.ID) && keep(r) {
+			repositories = append(repositories, r)
+		}
+	}
+	sort.Slice(repositories, func(i, j int) bool {
+		return *repositories[i].ID < *repositories[j].ID
+	})
+
+	lo, hi := opts.bounds(len(repositories))
+	return repositories[lo:hi]
+}
+
+// ListFeatures implements the Store interface.
+func (m *Memory) ListFeatures(opts ListOptions) ([]model.Feature, int64, error) {
+	features := m.filterFeatures(opts, func(model.Feature) bool { return true })
+	return features, int64(len(m.Features)), nil
+}
+
+// FeaturesByCategory implements the Store interface.
+func (m *Memory) FeaturesByCategory(category string, opts ListOptions) ([]model.Feature, error) {
+	return m.filterFeatures(opts, func(f model.Feature) bool {
+		return equalFold(f.Category, category)
+	}), nil
+}
+
+func (m *Memory) filterFeatures(opts ListOptions, keep func(model.Feature) bool) []model.Feature {
+	features := make([]model.Feature, 0)
+	for _, f := range m.Features {
+		if opts.since(f.ID) && keep(f) {
+			features = append(features, f)
+		}
+	}
+	sort.Slice(features, func(i, j int) bool { return *features[i].ID < *features[j].ID })
+
+	lo, hi := opts.bounds(len(features))
+	return features[lo:hi]
+}
+
+// FeatureScores implements the Store interface.
+func (m *Memory) FeatureScores(name string, opts ListOptions) ([]model.UserScore, int64, error) {
+	f := m.featureByName(name)
+	if f == nil || f.ID == nil {
+		return nil, 0, ErrNotFound
+	}
+
+	users := make([]model.UserScore, 0)
+
+	var total int64
+	for _, s := range m.Scores {
+		if s.FeatureID == nil || *s.FeatureID != *f.ID {
+			continue
+		}
+		u := m.userByID(s.UserID)
+		if u == nil {
+			continue
+		}
+		total++
+		if opts.since(u.ID) {
+			users = append(users, model.UserScore{ID: u.ID, Username: u.Username, Score: s.Score})
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return *users[i].ID < *users[j].ID })
+
+	lo, hi := opts.bounds(len(users))
+	return users[lo:hi], total, nil
+}
@@ -0,0 +1,565 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package store
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/golang/glog"
+	"github.com/lib/pq"
+
+	"github.com/DevMine/api-server/model"
+	"github.com/DevMine/api-server/util/apiutil"
+)
+
+const selectUsers = `
+SELECT
+    u.id, u.username, u.name, u.email,
+    ghu.id, ghu.github_id, ghu.login, ghu.bio, ghu.blog, ghu.company,
+    ghu.email, ghu.hireable, ghu.location, ghu.avatar_url, ghu.html_url,
+    ghu.followers_count, ghu.following_count, ghu.collaborators_count,
+    ghu.created_at, ghu.updated_at,
+    array_agg(DISTINCT row(gho.id, gho.github_id, gho.login, gho.avatar_url,
+        gho.html_url, gho.name, gho.company, gho.blog, gho.location, gho.email,
+        gho.collaborators_count, gho.created_at, gho.updated_at)) AS gh_orgs
+FROM users AS u
+LEFT OUTER JOIN gh_users AS ghu ON u.id = ghu.user_id
+JOIN gh_users_organizations AS ghuo ON ghu.id = ghuo.gh_user_id
+LEFT OUTER JOIN gh_organizations AS gho ON ghuo.gh_organization_id = gho.id `
+
+const selectRepositories = `
+SELECT
+	r.id, r.name, r.primary_language, r.clone_url, r.clone_path, r.vcs,
+	ghr.id, ghr.github_id, ghr.full_name, ghr.description, ghr.homepage,
+	ghr.fork, ghr.default_branch, ghr.master_branch, ghr.html_url,
+	ghr.forks_count, ghr.open_issues_count, ghr.stargazers_count,
+	ghr.subscribers_count, ghr.watchers_count, ghr.size_in_kb,
+	ghr.created_at, ghr.updated_at, ghr.pushed_at
+FROM repositories AS r
+LEFT OUTER JOIN gh_repositories AS ghr
+ON ghr.repository_id = r.id `
+
+const selectFeatures = `
+SELECT
+    f.id, f.name, f.category, f.default_weight
+FROM features AS f `
+
+// postgres is a Store backed by a PostgreSQL database.
+type postgres struct {
+	db *sql.DB
+}
+
+// NewPostgres creates a Store backed by a PostgreSQL database.
+func NewPostgres(db *sql.DB) (Store, error) {
+	if db == nil {
+		return nil, errors.New("database session cannot be nil")
+	}
+	return &postgres{db: db}, nil
+}
+
+// translateError replaces *err by ErrUnavailable when it indicates that the
+// database cannot be reached. It is meant to be deferred by the methods
+// implementing the Store interface.
+func translateError(err *error) {
+	if *err == nil || !isUnavailable(*err) {
+		return
+	}
+	glog.Error(*err)
+	*err = ErrUnavailable
+}
+
+// isUnavailable reports whether err indicates that the database cannot be
+// reached or cannot serve queries for now.
+func isUnavailable(err error) bool {
+	if err == driver.ErrBadConn || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "08", // connection exception
+			"53", // insufficient resources
+			"57": // operator intervention
+			return true
+		}
+	}
+
+	return false
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanUser scans a row selected with selectUsers.
+func scanUser(s scanner) (*model.User, error) {
+	var u model.User
+	var ghu model.GhUser
+	var ghOrgsArray string
+
+	if err := s.Scan(
+		&u.ID, &u.Username, &u.Name, &u.Email,
+		&ghu.ID, &ghu.GithubID, &ghu.Login,
+		&ghu.Bio, &ghu.Blog, &ghu.Company, &ghu.Email,
+		&ghu.Hireable, &ghu.Location, &ghu.AvatarURL,
+		&ghu.HTMLURL, &ghu.FollowersCount, &ghu.FollowingCount,
+		&ghu.CollaboratorsCount, &ghu.CreatedAt, &ghu.UpdatedAt,
+		&ghOrgsArray); err != nil {
+		return nil, err
+	}
+	ghu.GhOrganizations = apiutil.CreateGhOrgsFromPGArray(ghOrgsArray)
+	u.GhUser = &ghu
+
+	return &u, nil
+}
+
+// scanRepository scans a row selected with selectRepositories.
+func scanRepository(s scanner) (*model.Repository, error) {
+	var r model.Repository
+	var ghr model.GhRepository
+
+	if err := s.Scan(
+		&r.ID, &r.Name, &r.PrimaryLanguage, &r.CloneURL, &r.ClonePath,
+		&r.VCS, &ghr.ID, &ghr.GithubID, &ghr.FullName, &ghr.Description,
+		&ghr.Homepage, &ghr.Fork, &ghr.DefaultBranch, &ghr.MasterBranch,
+		&ghr.HTMLURL, &ghr.ForksCount, &ghr.OpenIssuesCount, &ghr.StargazersCount,
+		&ghr.SubscribersCount, &ghr.WatchersCount, &ghr.SizeInKb, &ghr.CreatedAt,
+		&ghr.UpdatedAt, &ghr.PushedAt); err != nil {
+		return nil, err
+	}
+	if ghr.ID != nil {
+		r.GhRepository = &ghr
+	}
+
+	return &r, nil
+}
+
+// limit returns the value of the LIMIT clause corresponding to the options:
+// NULL, which means no limit, when Limit is 0.
+func (opts ListOptions) limit() interface{} {
+	if opts.Limit == 0 {
+		return nil
+	}
+	return opts.Limit
+}
+
+// queryUsers runs a query selecting users with selectUsers. When each is not
+// nil, users are passed to it instead of being returned.
+func (p *postgres) queryUsers(each func(interface{}) error, query string, args ...interface{}) ([]model.User, error) {
+	rows, err := p.db.Query(selectUsers+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]model.User, 0)
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			glog.Error(err)
+			continue
+		}
+		if each != nil {
+			if err := each(*u); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		users = append(users, *u)
+	}
+
+	return users, rows.Err()
+}
+
+// queryRepositories runs a query selecting repositories. The query must
+// select the same columns as selectRepositories. When each is not nil,
+// repositories are passed to it instead of being returned.
+func (p *postgres) queryRepositories(each func(interface{}) error, query string, args ...interface{}) ([]model.Repository, error) {
+	rows, err := p.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	repositories := make([]model.Repository, 0)
+	for rows.Next() {
+		r, err := scanRepository(rows)
+		if err != nil {
+			glog.Error(err)
+			continue
+		}
+		if each != nil {
+			if err := each(*r); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		repositories = append(repositories, *r)
+	}
+
+	return repositories, rows.Err()
+}
+
+// queryFeatures runs a query selecting features with selectFeatures. When
+// each is not nil, features are passed to it instead of being returned.
+func (p *postgres) queryFeatures(each func(interface{}) error, query string, args ...interface{}) ([]model.Feature, error) {
+	rows, err := p.db.Query(selectFeatures+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	features := make([]model.Feature, 0)
+	for rows.Next() {
+		var f model.Feature
+		if err := rows.Scan(&f.ID, &f.Name, &f.Category, &f.DefaultWeight); err != nil {
+			glog.Error(err)
+			continue
+		}
+		if each != nil {
+			if err := each(f); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		features = append(features, f)
+	}
+
+	return features, rows.Err()
+}
+
+// count runs a query returning a single count.
+func (p *postgres) count(query string, args ...interface{}) (int64, error) {
+	var n int64
+	err := p.db.QueryRow(query, args...).Scan(&n)
+	return n, err
+}
+
+// exists runs a query returning whether a row exists.
+func (p *postgres) exists(query string, args ...interface{}) (bool, error) {
+	var b bool
+	err := p.db.QueryRow(`SELECT EXISTS(`+query+`)`, args...).Scan(&b)
+	return b, err
+}
+
+// checkUser returns ErrNotFound if there is no user with the given username.
+func (p *postgres) checkUser(username string) error {
+	ok, err := p.exists(`
+		SELECT 1 FROM users AS u
+		WHERE LOWER(u.username) = LOWER($1)`,
+		username)
+	if err == nil && !ok {
+		err = ErrNotFound
+	}
+	return err
+}
+
+// checkFeature returns ErrNotFound if there is no feature with the given name.
+func (p *postgres) checkFeature(name string) error {
+	ok, err := p.exists(`
+		SELECT 1 FROM features AS f
+		WHERE LOWER(f.name) = LOWER($1)`,
+		name)
+	if err == nil && !ok {
+		err = ErrNotFound
+	}
+	return err
+}
+
+// ListUsers implements the Store interface.
+func (p *postgres) ListUsers(opts ListOptions) (users []model.User, total int64, err error) {
+	defer translateError(&err)
+
+	users, err = p.queryUsers(opts.Each,
+		`WHERE u.id >= $1
+         GROUP BY ghu.id, u.id
+         ORDER BY u.id ASC
+         LIMIT $2 OFFSET $3`,
+		opts.SinceID,
+		opts.limit(),
+		opts.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err = p.count(`
+		SELECT COUNT(DISTINCT u.id)
+		FROM users AS u
+		LEFT OUTER JOIN gh_users AS ghu ON u.id = ghu.user_id
+		JOIN gh_users_organizations AS ghuo ON ghu.id = ghuo.gh_user_id`)
+	return users, total, err
+}
+
+// UserByUsername implements the Store interface.
+func (p *postgres) UserByUsername(username string) (u *model.User, err error) {
+	defer translateError(&err)
+
+	u, err = scanUser(p.db.QueryRow(selectUsers+
+		`WHERE LOWER(u.username) = LOWER($1)
+         GROUP BY ghu.id, u.id`,
+		username))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return u, err
+}
+
+// CommitsByAuthor implements the Store interface.
+func (p *postgres) CommitsByAuthor(username string, opts ListOptions) (commits []model.Commit, total int64, err error) {
+	defer translateError(&err)
+
+	// the author, the committer and the repository of each commit are
+	// selected along with the commit, without their GitHub information, so
+	// that commits can be passed to Each as they are scanned
+	rows, err := p.db.Query(`
+        SELECT
+            c.id, c.message, c.author_date, c.commit_date,
+            c.file_changed_count, c.insertions_count, c.deletions_count,
+            u.id, u.username, u.name, u.email,
+            cu.id, cu.username, cu.name, cu.email,
+            r.id, r.name, r.primary_language, r.clone_url, r.clone_path, r.vcs
+        FROM commits AS c
+        INNER JOIN users AS u
+        ON u.id=c.author_id
+        LEFT OUTER JOIN users AS cu
+        ON cu.id=c.committer_id
+        LEFT OUTER JOIN repositories AS r
+        ON r.id=c.repository_id
+        WHERE c.id >= $1
+        AND LOWER(u.username) = LOWER($2)
+        ORDER BY c.id ASC
+        LIMIT $3 OFFSET $4`,
+		opts.SinceID,
+		username,
+		opts.limit(),
+		opts.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	commits = make([]model.Commit, 0)
+	scanned := 0
+	for rows.Next() {
+		var co model.Commit
+		var author, committer model.User
+		var repo model.Repository
+
+		if err := rows.Scan(
+			&co.ID, &co.Message, &co.AuthorDate, &co.CommitDate,
+			&co.FileChangedCount, &co.InsertionsCount, &co.DeletionsCount,
+			&author.ID, &author.Username, &author.Name, &author.Email,
+			&committer.ID, &committer.Username, &committer.Name, &committer.Email,
+			&repo.ID, &repo.Name, &repo.PrimaryLanguage, &repo.CloneURL,
+			&repo.ClonePath, &repo.VCS); err != nil {
+			glog.Error(err)
+			continue
+		}
+
+		co.Author = &author
+		if committer.ID != nil {
+			co.Committer = &committer
+		}
+		if repo.ID != nil {
+			co.Repository = &repo
+		}
+		scanned++
+
+		if opts.Each != nil {
+			if err := opts.Each(co); err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+		commits = append(commits, co)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// rows must be closed before issuing new queries, otherwise they would
+	// need an extra connection
+	rows.Close()
+
+	if scanned == 0 {
+		if err := p.checkUser(username); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	total, err = p.count(`
+		SELECT COUNT(c.id)
+		FROM commits AS c
+		INNER JOIN users AS u
+		ON u.id=c.author_id
+		WHERE LOWER(u.username) = LOWER($1)`,
+		username)
+	return commits, total, err
+}
+
+// RepositoriesByUser implements the Store interface.
+func (p *postgres) RepositoriesByUser(username string, opts ListOptions) (repositories []model.Repository, err error) {
+	defer translateError(&err)
+
+	repositories, err = p.queryRepositories(opts.Each, selectRepositories+`
+		INNER JOIN users_repositories AS ur
+		ON r.id = ur.repository_id
+		INNER JOIN users AS u
+		ON ur.user_id = u.id
+		WHERE LOWER(u.username) = LOWER($1)
+		GROUP BY r.id, ghr.id
+		ORDER BY r.id ASC
+		LIMIT $2 OFFSET $3`,
+		username,
+		opts.limit(),
+		opts.Offset)
+	if err == nil && len(repositories) == 0 {
+		err = p.checkUser(username)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return repositories, nil
+}
+
+// ListRepositories implements the Store interface.
+func (p *postgres) ListRepositories(opts ListOptions) (repositories []model.Repository, total int64, err error) {
+	defer translateError(&err)
+
+	repositories, err = p.queryRepositories(opts.Each, selectRepositories+`
+		WHERE r.id >= $1
+		GROUP BY ghr.id, r.id
+		ORDER BY r.id ASC
+		LIMIT $2 OFFSET $3`,
+		opts.SinceID,
+		opts.limit(),
+		opts.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err = p.count(`SELECT COUNT(r.id) FROM repositories AS r`)
+	return repositories, total, err
+}
+
+// RepositoriesByName implements the Store interface.
+func (p *postgres) RepositoriesByName(name string, opts ListOptions) (repositories []model.Repository, err error) {
+	defer translateError(&err)
+
+	return p.queryRepositories(opts.Each, selectRepositories+`
+		WHERE LOWER(r.name) = LOWER($1)
+		AND r.id >= $2
+		GROUP BY ghr.id, r.id
+		ORDER BY r.id ASC
+		LIMIT $3 OFFSET $4`,
+		name,
+		opts.SinceID,
+		opts.limit(),
+		opts.Offset)
+}
+
+// ListFeatures implements the Store interface.
+func (p *postgres) ListFeatures(opts ListOptions) (features []model.Feature, total int64, err error) {
+	defer translateError(&err)
+
+	features, err = p.queryFeatures(opts.Each, `
+		WHERE f.id >= $1
+        ORDER BY f.id ASC
+        LIMIT $2 OFFSET $3`,
+		opts.SinceID,
+		opts.limit(),
+		opts.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err = p.count(`SELECT COUNT(f.id) FROM features AS f`)
+	return features, total, err
+}
+
+// FeaturesByCategory implements the Store interface.
+func (p *postgres) FeaturesByCategory(category string, opts ListOptions) (features []model.Feature, err error) {
+	defer translateError(&err)
+
+	return p.queryFeatures(opts.Each, `
+		WHERE f.id >= $1
+        AND LOWER(f.category) = LOWER($2)
+        ORDER BY f.id ASC
+        LIMIT $3 OFFSET $4`,
+		opts.SinceID,
+		category,
+		opts.limit(),
+		opts.Offset)
+}
+
+// FeatureScores implements the Store interface.
+func (p *postgres) FeatureScores(name string, opts ListOptions) (users []model.UserScore, total int64, err error) {
+	defer translateError(&err)
+
+	rows, err := p.db.Query(`
+		SELECT s.score, u.id, u.username
+		FROM scores AS s
+		INNER JOIN users AS u ON s.user_id = u.id
+		INNER JOIN features AS f ON f.id = s.feature_id
+		WHERE LOWER(f.name) = LOWER($1)
+		AND u.id >= $2
+		ORDER BY u.id
+		LIMIT $3 OFFSET $4`,
+		name,
+		opts.SinceID,
+		opts.limit(),
+		opts.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users = make([]model.UserScore, 0)
+
+	for rows.Next() {
+		var u model.UserScore
+		if err := rows.Scan(&u.Score, &u.ID, &u.Username); err != nil {
+			glog.Error(err)
+			continue
+		}
+
+		if opts.Each != nil {
+			if err := opts.Each(u); err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if len(users) == 0 {
+		if err := p.checkFeature(name); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	total, err = p.count(`
+		SELECT COUNT(s.id)
+		FROM scores AS s
+		INNER JOIN users AS u ON s.user_id = u.id
+		INNER JOIN features AS f ON f.id = s.feature_id
+		WHERE LOWER(f.name) = LOWER($1)`,
+		name)
+	return users, total, err
+}
@@ -0,0 +1,85 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package store provides access to the data served by the API server.
+// Route handlers shall only access the data through the Store interface so
+// that they do not depend on a particular storage backend. Two
+// implementations are provided: one backed by a PostgreSQL database and an
+// in-memory one, mostly useful to run the server with fixtures.
+package store
+
+import (
+	"errors"
+
+	"github.com/DevMine/api-server/model"
+)
+
+var (
+	// ErrNotFound is returned when a requested item does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrUnavailable is returned when the storage backend cannot be reached.
+	ErrUnavailable = errors.New("storage backend unavailable")
+)
+
+// ListOptions specifies which part of a list of items to return.
+type ListOptions struct {
+	// SinceID corresponds to an ID since which to return items.
+	SinceID uint64
+
+	// Offset is the number of items to skip, after applying SinceID.
+	Offset uint64
+
+	// Limit is the maximum number of items to return. A Limit of 0 means no
+	// limit.
+	Limit uint64
+
+	// Each, when not nil, is called with each item of a list, in order, as
+	// soon as it is read, instead of returning the item. This allows
+	// streaming lists without holding them in memory. When Each returns an
+	// error, listing stops and the error is returned.
+	// Backends may ignore Each and return the items instead, which callers
+	// must handle as well.
+	Each func(item interface{}) error
+}
+
+// Store is the interface implemented by storage backends.
+// Unless stated otherwise, lists are sorted by ascending IDs.
+// Methods returning a total also return the total number of items in the
+// list, regardless of the list options.
+// Methods listing items belonging to a user or a feature return ErrNotFound
+// when the user or the feature does not exist.
+type Store interface {
+	// ListUsers returns users, with their GitHub information.
+	ListUsers(opts ListOptions) (users []model.User, total int64, err error)
+
+	// UserByUsername returns the user with the given username, with its
+	// GitHub information. Username matching is case insensitive.
+	UserByUsername(username string) (*model.User, error)
+
+	// CommitsByAuthor returns the commits authored by the given user.
+	CommitsByAuthor(username string, opts ListOptions) (commits []model.Commit, total int64, err error)
+
+	// RepositoriesByUser returns the repositories associated to the given
+	// user. The SinceID option is ignored.
+	RepositoriesByUser(username string, opts ListOptions) ([]model.Repository, error)
+
+	// ListRepositories returns repositories, with their GitHub information.
+	ListRepositories(opts ListOptions) (repositories []model.Repository, total int64, err error)
+
+	// RepositoriesByName returns the repositories with the given name.
+	// Name matching is case insensitive.
+	RepositoriesByName(name string, opts ListOptions) ([]model.Repository, error)
+
+	// ListFeatures returns features.
+	ListFeatures(opts ListOptions) (features []model.Feature, total int64, err error)
+
+	// FeaturesByCategory returns the features of the given category.
+	// Category matching is case insensitive.
+	FeaturesByCategory(category string, opts ListOptions) ([]model.Feature, error)
+
+	// FeatureScores returns the scores of all users for the given feature,
+	// sorted by ascending user IDs. The SinceID option applies to user IDs.
+	FeatureScores(name string, opts ListOptions) (scores []model.UserScore, total int64, err error)
+}
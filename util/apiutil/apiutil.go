@@ -7,7 +7,6 @@
 package apiutil
 
 import (
-	"database/sql"
 	"errors"
 
 	"github.com/golang/glog"
@@ -110,41 +109,3 @@ func CreateGhOrgsFromPGArray(pgArray string) []*model.GhOrganization {
 
 	return ghOrgs
 }
-
-// FetchUser retrieves a user from the database.
-func FetchUser(db *sql.DB, id *int64) *model.User {
-	if id == nil || db == nil {
-		return nil
-	}
-
-	var u model.User
-	err := db.QueryRow(`
-        SELECT id, username, name, email
-        FROM users
-        WHERE id=$1`,
-		*id).Scan(&u.ID, &u.Username, &u.Name, &u.Email)
-	if err != nil {
-		panic(err)
-	}
-
-	return &u
-}
-
-// FetchRepository retrieves a repository from the database.
-func FetchRepository(db *sql.DB, id *int64) *model.Repository {
-	if id == nil || db == nil {
-		return nil
-	}
-
-	var r model.Repository
-	err := db.QueryRow(`
-        SELECT id, name, primary_language, clone_url, clone_path, vcs
-        FROM repositories
-        WHERE id=$1`,
-		*id).Scan(&r.ID, &r.Name, &r.PrimaryLanguage, &r.CloneURL, &r.ClonePath, &r.VCS)
-	if err != nil {
-		panic(err)
-	}
-
-	return &r
-}
@@ -7,12 +7,73 @@ package httputil
 
 import (
 	"encoding/json"
+	"net/http"
 )
 
+// Error codes. They are meant to be processed by API clients, hence shall
+// never change once defined.
+const (
+	CodeBadRequest         = "bad_request"
+	CodeInvalidJSON        = "invalid_json"
+	CodeInvalidParameter   = "invalid_parameter"
+	CodeUnknownFeature     = "unknown_feature"
+	CodeInvalidWeight      = "invalid_weight"
+	CodeUnauthorized       = "unauthorized"
+	CodeNotFound           = "not_found"
+	CodeNotAcceptable      = "not_acceptable"
+	CodeConflict           = "conflict"
+	CodeRequestTooLarge    = "request_too_large"
+	CodeRateLimited        = "rate_limited"
+	CodeInternalError      = "internal_error"
+	CodeServiceUnavailable = "service_unavailable"
+)
+
+// Error is an error meant to be reported to API clients. Route handlers shall
+// return an Error when a request cannot be fulfilled.
+type Error struct {
+	// Status is the HTTP status code of the response.
+	Status int
+
+	// Code is a machine readable error code.
+	Code string
+
+	// Message is an informative message about the error.
+	Message string
+}
+
+// NewError creates a new Error.
+func NewError(status int, code, msg string) *Error {
+	return &Error{Status: status, Code: code, Message: msg}
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// BadRequest creates a new Error with status 400 Bad Request.
+func BadRequest(code, msg string) *Error {
+	return NewError(http.StatusBadRequest, code, msg)
+}
+
+// NotFound creates a new Error with status 404 Not Found.
+func NotFound(msg string) *Error {
+	return NewError(http.StatusNotFound, CodeNotFound, msg)
+}
+
 // ResponseError shall be used when an error response shall be returned.
 type ResponseError struct {
 	// Message shall be an informative message about the error.
 	Message string `json:"message"`
+
+	// Code is a machine readable error code.
+	Code string `json:"code,omitempty"`
+
+	// DocumentationURL points to the documentation related to the error.
+	DocumentationURL string `json:"documentation_url,omitempty"`
+
+	// RequestID identifies the request which caused the error.
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // NewResponseError creates a new ResponseError.
@@ -31,3 +92,11 @@ func (re *ResponseError) JSON() string {
 	}
 	return string(bs)
 }
+
+// WriteError writes the error response re with the given HTTP status code.
+func WriteError(w http.ResponseWriter, re *ResponseError, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write([]byte(re.JSON()))
+}
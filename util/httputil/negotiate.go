@@ -0,0 +1,119 @@
+// Copyright 2014-2015 The DevMine authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httputil
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MediaTypeNDJSON is the media type of newline delimited JSON, where each
+// line is a JSON value.
+const MediaTypeNDJSON = "application/x-ndjson"
+
+// qValue is an element of a header value made of a list of elements with
+// optional quality values, such as the Accept or Accept-Encoding headers.
+type qValue struct {
+	value string
+	q     float64
+}
+
+// parseQValues parses a header value such as "text/html;q=0.8, */*;q=0.1".
+// Parameters other than the quality value are ignored.
+func parseQValues(header string) []qValue {
+	var qvs []qValue
+	for _, elem := range strings.Split(header, ",") {
+		parts := strings.Split(elem, ";")
+		qv := qValue{value: strings.ToLower(strings.TrimSpace(parts[0])), q: 1}
+		if len(qv.value) == 0 {
+			continue
+		}
+
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+				qv.q = q
+			}
+		}
+		qvs = append(qvs, qv)
+	}
+	return qvs
+}
+
+// mediaTypeQ returns the quality value given to the media type mt by the
+// most specific matching media range of the Accept header, or -1 if no media
+// range matches.
+func mediaTypeQ(accept []qValue, mt string) float64 {
+	q, specificity := -1.0, -1
+	mainType := strings.SplitN(mt, "/", 2)[0]
+
+	for _, a := range accept {
+		var s int
+		switch {
+		case a.value == mt:
+			s = 2
+		case a.value == mainType+"/*":
+			s = 1
+		case a.value == "*/*":
+			s = 0
+		default:
+			continue
+		}
+		if s > specificity {
+			q, specificity = a.q, s
+		}
+	}
+	return q
+}
+
+// NegotiateMediaType chooses, among the offered media types, the one that
+// best matches the Accept header value. Offers are given by order of
+// preference of the server. An empty Accept header accepts anything.
+// It returns false if none of the offers is acceptable.
+func NegotiateMediaType(accept string, offers []string) (string, bool) {
+	if len(offers) == 0 {
+		return "", false
+	}
+	if len(strings.TrimSpace(accept)) == 0 {
+		return offers[0], true
+	}
+
+	qvs := parseQValues(accept)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := mediaTypeQ(qvs, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// NegotiateEncoding chooses, among the offered content codings, the one that
+// best matches the Accept-Encoding header value. Offers are given by order of
+// preference of the server. It returns an empty string when no content coding
+// shall be applied ("identity").
+func NegotiateEncoding(acceptEncoding string, offers []string) string {
+	qvs := parseQValues(acceptEncoding)
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q := -1.0
+		for _, qv := range qvs {
+			if qv.value == offer {
+				q = qv.q
+				break
+			} else if qv.value == "*" {
+				q = qv.q
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}